@@ -10,38 +10,96 @@ import (
 // Generated with:
 // https://zhwt.github.io/yaml-to-go/
 type Config struct {
-	NodeName string    `yaml:"node_name"`
-	Hosts    []Host    `yaml:"hosts"`
-	Monitors []Monitor `yaml:"monitors"`
+	NodeName      string    `yaml:"node_name"`
+	MetricsListen string    `yaml:"metrics_listen,omitempty"`
+	Hosts         []Host    `yaml:"hosts"`
+	Monitors      []Monitor `yaml:"monitors"`
 }
 type Host struct {
-	Name string `yaml:"name"`
-	URL  string `yaml:"url"`
+	Name            string            `yaml:"name"`
+	Type            string            `yaml:"type,omitempty"`
+	URL             string            `yaml:"url,omitempty"`
+	URLs            []string          `yaml:"urls,omitempty"`
+	FailbackAfter   int               `yaml:"failback_after,omitempty"`
+	Timeout         int               `yaml:"timeout,omitempty"`
+	Method          string            `yaml:"method,omitempty"`
+	Path            string            `yaml:"path,omitempty"`
+	Query           map[string]string `yaml:"query,omitempty"`
+	Headers         map[string]string `yaml:"headers,omitempty"`
+	Body            string            `yaml:"body,omitempty"`
+	Job             string            `yaml:"job,omitempty"`
+	Token           string            `yaml:"token,omitempty"`
+	Org             string            `yaml:"org,omitempty"`
+	Bucket          string            `yaml:"bucket,omitempty"`
+	FlushInterval   int               `yaml:"flush_interval,omitempty"`
+	BatchSize       int               `yaml:"batch_size,omitempty"`
+	Username        string            `yaml:"username,omitempty"`
+	Password        string            `yaml:"password,omitempty"`
+	ClientID        string            `yaml:"client_id,omitempty"`
+	Topic           string            `yaml:"topic,omitempty"`
+	Discovery       bool              `yaml:"discovery,omitempty"`
+	DiscoveryPrefix string            `yaml:"discovery_prefix,omitempty"`
+	OnlyChanges     bool              `yaml:"only_changes,omitempty"`
+	Secret          string            `yaml:"secret,omitempty"`
+	SignatureHeader string            `yaml:"signature_header,omitempty"`
+	Facility        string            `yaml:"facility,omitempty"`
+	Severities      map[string]string `yaml:"severities,omitempty"`
+	Prefix          string            `yaml:"prefix,omitempty"`
+	ZabbixHost      string            `yaml:"zabbix_host,omitempty"`
+	Encryption      string            `yaml:"encryption,omitempty"`
+	Labels          map[string]string `yaml:"labels,omitempty"`
+	Severity        string            `yaml:"severity,omitempty"`
+	RefreshInterval int               `yaml:"refresh_interval,omitempty"`
+	From            string            `yaml:"from,omitempty"`
+	Recipients      []string          `yaml:"recipients,omitempty"`
+	Subject         string            `yaml:"subject,omitempty"`
+	ForceTLS        bool              `yaml:"force_tls,omitempty"`
+	RateLimit       int               `yaml:"rate_limit,omitempty"`
+	FilePath        string            `yaml:"file_path,omitempty"`
+	Format          string            `yaml:"format,omitempty"`
+	MaxSize         int               `yaml:"max_size,omitempty"`
+	MaxAge          int               `yaml:"max_age,omitempty"`
+	MaxBackups      int               `yaml:"max_backups,omitempty"`
+	Compress        bool              `yaml:"compress,omitempty"`
+	Provision       bool              `yaml:"provision,omitempty"`
+	ProvisionURL    string            `yaml:"provision_url,omitempty"`
+	TokenFile       string            `yaml:"token_file,omitempty"`
 }
 type Monitor struct {
-	Name                 string `yaml:"name"`
-	Type                 string `yaml:"type"`
-	Host                 string `yaml:"host"`
-	Key                  string `yaml:"key"`
-	Interval             int    `yaml:"interval"`
-	Timeout              int    `yaml:"timeout,omitempty"`
-	FilePath             string `yaml:"file_path,omitempty"`
-	DownThreshold        int    `yaml:"down_threshold,omitempty"`
-	SMTPHost             string `yaml:"smtp_host,omitempty"`
-	SMTPPort             int    `yaml:"smtp_port,omitempty"`
-	SMTPForceTLS         bool   `yaml:"smtp_force_tls,omitempty"`
-	SMTPSenderAddress    string `yaml:"smtp_sender_address,omitempty"`
-	SMTPRecipientAddress string `yaml:"smtp_recipient_address,omitempty"`
-	SMTPUsername         string `yaml:"smtp_username,omitempty"`
-	SMTPPassword         string `yaml:"smtp_password,omitempty"`
-	IMAPHost             string `yaml:"imap_host,omitempty"`
-	IMAPPort             int    `yaml:"imap_port,omitempty"`
-	IMAPForceTLS         bool   `yaml:"imap_force_tls,omitempty"`
-	IMAPUsername         string `yaml:"imap_username,omitempty"`
-	IMAPPassword         string `yaml:"imap_password,omitempty"`
-	MessageSubject       string `yaml:"message_subject,omitempty"`
-	MessageBody          string `yaml:"message_body,omitempty"`
-	ResponseSubject      string `yaml:"response_subject,omitempty"`
+	Name                 string        `yaml:"name"`
+	Type                 string        `yaml:"type"`
+	Host                 string        `yaml:"host,omitempty"`
+	Key                  string        `yaml:"key,omitempty"`
+	Hosts                []MonitorHost `yaml:"hosts,omitempty"`
+	Interval             int           `yaml:"interval"`
+	PushMode             string        `yaml:"push_mode,omitempty"`
+	Heartbeat            int           `yaml:"heartbeat,omitempty"`
+	Tags                 []string      `yaml:"tags,omitempty"`
+	ClickURL             string        `yaml:"click_url,omitempty"`
+	HeartbeatURL         string        `yaml:"heartbeat_url,omitempty"`
+	FailureURL           string        `yaml:"failure_url,omitempty"`
+	Timeout              int           `yaml:"timeout,omitempty"`
+	FilePath             string        `yaml:"file_path,omitempty"`
+	DownThreshold        int           `yaml:"down_threshold,omitempty"`
+	SMTPHost             string        `yaml:"smtp_host,omitempty"`
+	SMTPPort             int           `yaml:"smtp_port,omitempty"`
+	SMTPForceTLS         bool          `yaml:"smtp_force_tls,omitempty"`
+	SMTPSenderAddress    string        `yaml:"smtp_sender_address,omitempty"`
+	SMTPRecipientAddress string        `yaml:"smtp_recipient_address,omitempty"`
+	SMTPUsername         string        `yaml:"smtp_username,omitempty"`
+	SMTPPassword         string        `yaml:"smtp_password,omitempty"`
+	IMAPHost             string        `yaml:"imap_host,omitempty"`
+	IMAPPort             int           `yaml:"imap_port,omitempty"`
+	IMAPForceTLS         bool          `yaml:"imap_force_tls,omitempty"`
+	IMAPUsername         string        `yaml:"imap_username,omitempty"`
+	IMAPPassword         string        `yaml:"imap_password,omitempty"`
+	MessageSubject       string        `yaml:"message_subject,omitempty"`
+	MessageBody          string        `yaml:"message_body,omitempty"`
+	ResponseSubject      string        `yaml:"response_subject,omitempty"`
+}
+type MonitorHost struct {
+	Host string `yaml:"host"`
+	Key  string `yaml:"key"`
 }
 
 // Read and parse a yaml config at path
@@ -6,8 +6,6 @@ import (
 
 type aliveMonitor struct {
 	name     string
-	host     string
-	key      string
 	interval int
 }
 
@@ -19,24 +17,16 @@ func (m *aliveMonitor) Type() string {
 	return "alive"
 }
 
-func (m *aliveMonitor) HostURL() string {
-	return m.host
-}
-
-func (m *aliveMonitor) Key() string {
-	return m.key
-}
-
 func (m *aliveMonitor) Interval() int {
 	return m.interval
 }
 
-func (m *aliveMonitor) Run() (monitorStatus, string, int, error) {
+func (m *aliveMonitor) Run() (monitorStatus, string, int, map[string]float64, error) {
 	// Simply let the upstream host know that we are alive
-	return StatusUp, "OK", 0, nil
+	return StatusUp, "OK", 0, nil, nil
 }
 
 // Setup a monitor of type 'alive'
-func setupAliveMonitor(host string, monitor *config.Monitor) *aliveMonitor {
-	return &aliveMonitor{name: monitor.Name, host: host, interval: monitor.Interval, key: monitor.Key}
+func setupAliveMonitor(monitor *config.Monitor) *aliveMonitor {
+	return &aliveMonitor{name: monitor.Name, interval: monitor.Interval}
 }
@@ -0,0 +1,174 @@
+package monitors
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Default settings of batching backends
+const (
+	// Seconds between two flushes
+	defaultFlushInterval = 10
+	// Maximum number of entries delivered at once
+	defaultBatchSize = 500
+	// Maximum number of entries kept while deliveries fail
+	maxPendingEntries = 10000
+)
+
+// Returned by deliveries if the host rejected (part of) a batch, which is
+// dropped instead of retried as it would be rejected again
+type rejectedBatchError struct {
+	err error
+}
+
+func (e *rejectedBatchError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rejectedBatchError) Unwrap() error {
+	return e.err
+}
+
+// Whether a batch that failed with `err` must not be retried
+func isRejectedBatch(err error) bool {
+	var rejected *rejectedBatchError
+	return errors.As(err, &rejected) || isRejectedPayload(err)
+}
+
+// Collects entries and delivers them in batches from a background goroutine
+//
+// A batch is delivered once it is full or the flush interval elapsed. Batches
+// that fail to be delivered are kept and retried on the next flush, dropping
+// the oldest entries once too many are pending. Batches the host rejects as
+// invalid (400, 413 or 422) are dropped right away, as they would never be
+// accepted.
+type batcher[T any] struct {
+	// Name of the host the entries are delivered to, used in logs
+	host string
+	// Time between two flushes
+	flushInterval time.Duration
+	// Maximum number of entries delivered at once
+	batchSize int
+	// Delivers a single batch
+	deliver func(batch []T) error
+
+	mu      sync.Mutex
+	pending []T
+	// Total number of entries dropped from the front of pending
+	dropped int
+	// Signals the background goroutine that a batch is full
+	full chan struct{}
+}
+
+// Queue entries for delivery without blocking
+func (b *batcher[T]) add(entries ...T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending = append(b.pending, entries...)
+
+	if dropped := len(b.pending) - maxPendingEntries; dropped > 0 {
+		zap.S().Warnw("Too many pending entries, dropping oldest",
+			"host", b.host,
+			"dropped", dropped,
+		)
+		b.pending = b.pending[dropped:]
+		b.dropped += dropped
+	}
+
+	if len(b.pending) >= b.batchSize {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Deliver pending entries in batches until none are left or a delivery has to
+// be retried
+func (b *batcher[T]) flush() {
+	for {
+		b.mu.Lock()
+		size := len(b.pending)
+		if size > b.batchSize {
+			size = b.batchSize
+		}
+		batch := make([]T, size)
+		copy(batch, b.pending)
+		droppedBefore := b.dropped
+		b.mu.Unlock()
+
+		if len(batch) == 0 {
+			return
+		}
+
+		err := b.deliver(batch)
+		if err != nil && !isRejectedBatch(err) {
+			zap.S().Warnw("Error delivering batch, retrying on next flush",
+				"host", b.host,
+				"entries", len(batch),
+				"error", err,
+			)
+			return
+		}
+		if err != nil {
+			//? Retrying would block all later entries behind this batch
+			zap.S().Warnw("Batch rejected by host, dropping it",
+				"host", b.host,
+				"entries", len(batch),
+				"error", err,
+			)
+		}
+
+		// Part of the batch might have been dropped during the delivery
+		b.mu.Lock()
+		remaining := len(batch) - (b.dropped - droppedBefore)
+		if remaining > 0 {
+			b.pending = b.pending[remaining:]
+		}
+		b.mu.Unlock()
+	}
+}
+
+// Flush periodically or whenever a batch is full
+//
+// Should be called in a go-routine
+func (b *batcher[T]) run() {
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.full:
+		}
+
+		b.flush()
+	}
+}
+
+// Create a batcher and start delivering in the background
+//
+// `flushInterval` and `batchSize` fall back to their defaults if zero.
+func startBatcher[T any](host string, flushInterval int, batchSize int, deliver func(batch []T) error) *batcher[T] {
+	if flushInterval == 0 {
+		flushInterval = defaultFlushInterval
+	}
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+
+	b := &batcher[T]{
+		host:          host,
+		flushInterval: time.Duration(flushInterval) * time.Second,
+		batchSize:     batchSize,
+		deliver:       deliver,
+		full:          make(chan struct{}, 1),
+	}
+	go b.run()
+
+	return b
+}
@@ -0,0 +1,40 @@
+package monitors
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestBatcherDropsOnlyRejectedPayloads(t *testing.T) {
+	for _, tc := range []struct {
+		code int
+		kept bool
+	}{
+		{http.StatusBadRequest, false},
+		{http.StatusRequestEntityTooLarge, false},
+		{http.StatusUnprocessableEntity, false},
+		{http.StatusUnauthorized, true},
+		{http.StatusForbidden, true},
+		{http.StatusNotFound, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusServiceUnavailable, true},
+	} {
+		t.Run(fmt.Sprint(tc.code), func(t *testing.T) {
+			b := &batcher[int]{
+				host:      "test",
+				batchSize: 10,
+				deliver: func(batch []int) error {
+					return fmt.Errorf("write failed: %w", &statusError{code: tc.code})
+				},
+				full: make(chan struct{}, 1),
+			}
+			b.add(1, 2, 3)
+			b.flush()
+
+			if kept := len(b.pending) == 3; kept != tc.kept {
+				t.Errorf("pending = %v, want kept = %v", b.pending, tc.kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package monitors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default time in seconds a single push request may take
+const defaultPushTimeout = 30
+
+// Shared by all hosts so connections to the same server are reused between
+// pushes instead of opening a new one for every result
+var pushClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	},
+}
+
+// Send `req` using the shared push client and record the outcome in `stats`
+//
+// The response body is always drained and closed so the connection can be
+// reused, only the status code is returned.
+func doPushRequest(req *http.Request, timeout time.Duration, stats *pushStats) (int, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := pushClient.Do(req.WithContext(ctx))
+	if err != nil {
+		stats.record(time.Since(start), err)
+		return 0, err
+	}
+
+	_, err = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	latency := time.Since(start)
+
+	if err != nil {
+		err = fmt.Errorf("failed to read response: %w", err)
+	} else if !isSuccessStatus(resp.StatusCode) {
+		err = &statusError{code: resp.StatusCode}
+	}
+	stats.record(latency, err)
+
+	return resp.StatusCode, err
+}
+
+// Whether an HTTP status code signals a successful push
+func isSuccessStatus(code int) bool {
+	return code >= 200 && code < 300
+}
+
+// Returned if a host answered a push with an unsuccessful status code
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code %v", e.code)
+}
+
+// Whether `err` is a 4xx response, i.e. the host is reachable but rejected
+// this particular request
+func isClientError(err error) bool {
+	var statusErr *statusError
+	return errors.As(err, &statusErr) && statusErr.code >= 400 && statusErr.code < 500
+}
+
+// Whether `err` is a response rejecting the payload itself as invalid (400),
+// too large (413) or unprocessable (422), so sending it again would fail again
+//
+// Other 4xx responses, e.g. due to an expired token (401, 403) or a bucket
+// that does not exist yet (404), may succeed later.
+func isRejectedPayload(err error) bool {
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+
+	switch statusErr.code {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return true
+	default:
+		return false
+	}
+}
+
+// Statistics about the pushes to a single host
+type hostStats struct {
+	successes   uint64
+	failures    uint64
+	lastLatency time.Duration
+	lastSuccess time.Time
+	lastFailure time.Time
+	// Empty if no push failed yet
+	lastError string
+}
+
+// Collects the statistics of a host, safe for concurrent use
+type pushStats struct {
+	mu    sync.Mutex
+	stats hostStats
+}
+
+// Record the outcome of a single push
+func (s *pushStats) record(latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.lastLatency = latency
+	if err != nil {
+		s.stats.failures++
+		s.stats.lastFailure = time.Now()
+		s.stats.lastError = err.Error()
+	} else {
+		s.stats.successes++
+		s.stats.lastSuccess = time.Now()
+	}
+}
+
+// Get a consistent copy of the current statistics
+func (s *pushStats) snapshot() hostStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
@@ -12,8 +12,6 @@ import (
 
 type diskUsageMonitor struct {
 	name     string
-	host     string
-	key      string
 	interval int
 
 	// Linux: stat -f {fileInFilesystem}, Windows: drive letter
@@ -30,19 +28,11 @@ func (m *diskUsageMonitor) Type() string {
 	return "disk_usage"
 }
 
-func (m *diskUsageMonitor) HostURL() string {
-	return m.host
-}
-
-func (m *diskUsageMonitor) Key() string {
-	return m.key
-}
-
 func (m *diskUsageMonitor) Interval() int {
 	return m.interval
 }
 
-func (m *diskUsageMonitor) Run() (monitorStatus, string, int, error) {
+func (m *diskUsageMonitor) Run() (monitorStatus, string, int, map[string]float64, error) {
 	// Get disk usage
 	zap.S().Debugw("Getting disk usage",
 		"name", m.name,
@@ -61,7 +51,7 @@ func (m *diskUsageMonitor) Run() (monitorStatus, string, int, error) {
 		)
 
 		// We want to still push this error to the uptime host
-		return StatusDown, "Error getting disk usage", 0, nil
+		return StatusDown, "Error getting disk usage", 0, nil, nil
 	}
 
 	// The normal .Usage() uses the complete .Free() instead of the actually
@@ -88,11 +78,15 @@ func (m *diskUsageMonitor) Run() (monitorStatus, string, int, error) {
 		"message", message,
 	)
 
-	return status, message, percentage, nil
+	values := map[string]float64{
+		"usage_percent": float64(percentage),
+	}
+
+	return status, message, percentage, values, nil
 }
 
 // Setup a monitor of type 'disk_usage'
-func setupDiskUsageMonitor(host string, monitor *config.Monitor) *diskUsageMonitor {
+func setupDiskUsageMonitor(monitor *config.Monitor) *diskUsageMonitor {
 	if monitor.FilePath == "" {
 		zap.S().Panicw("Missing paramter for monitor",
 			"name", monitor.Name,
@@ -111,9 +105,7 @@ func setupDiskUsageMonitor(host string, monitor *config.Monitor) *diskUsageMonit
 
 	return &diskUsageMonitor{
 		name:          monitor.Name,
-		host:          host,
 		interval:      monitor.Interval,
-		key:           monitor.Key,
 		filePath:      monitor.FilePath,
 		downThreshold: monitor.DownThreshold,
 	}
@@ -0,0 +1,152 @@
+package monitors
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Returned if STARTTLS is forced but the SMTP server does not support it
+var errSTARTTLSUnsupported = errors.New("STARTTLS extension forced but no support")
+
+// A single email sent by notifiers
+type emailMessage struct {
+	from *mail.Address
+	to   []*mail.Address
+
+	subject string
+	body    string
+}
+
+// SMTP server emails are sent with, used by the email_ping monitor and the
+// email host
+type smtpServer struct {
+	host     string
+	port     int
+	forceTLS bool
+	username string
+	password string
+	// Authenticate even if no username is set, otherwise authentication is
+	// skipped in that case
+	requireAuth bool
+	// End the session with QUIT, otherwise the connection is only closed
+	quit bool
+	// Time connecting and sending a single email may take, 0 for no limit
+	timeout time.Duration
+}
+
+// Send a single email
+//
+// `msg` contains the headers and body of the email, `from` and `to` are only
+// used as envelope.
+func (s *smtpServer) send(from *mail.Address, to []*mail.Address, msg []byte) error {
+	// Connect to the SMTP server
+	smtpAddress := net.JoinHostPort(s.host, fmt.Sprint(s.port))
+	netConn, err := net.DialTimeout("tcp", smtpAddress, s.timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %v", err)
+	}
+	if s.timeout > 0 {
+		netConn.SetDeadline(time.Now().Add(s.timeout))
+	}
+
+	conn, err := smtp.NewClient(netConn, s.host)
+	if err != nil {
+		netConn.Close()
+		return fmt.Errorf("failed to connect to SMTP server: %v", err)
+	}
+	defer conn.Close()
+	zap.S().Debugw("SMTP dialed", "address", smtpAddress)
+
+	// STARTTLS
+	if ok, _ := conn.Extension("STARTTLS"); ok {
+		config := &tls.Config{
+			ServerName: s.host,
+		}
+		if err = conn.StartTLS(config); err != nil {
+			return fmt.Errorf("failed to starttls: %v", err)
+		}
+		zap.S().Debugln("SMTP STARTTLS completed")
+	} else if s.forceTLS {
+		zap.S().Debugln("SMTP STARTTLS extension forced but no support")
+		return errSTARTTLSUnsupported
+	} else {
+		zap.S().Debugln("SMTP continuing with unencrypted connection!")
+	}
+
+	// Authenticate
+	if s.requireAuth || s.username != "" {
+		auth := smtp.PlainAuth("", s.username, s.password, s.host)
+		if err := conn.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %v", err)
+		}
+		zap.S().Debugln("SMTP authenticated")
+	}
+
+	// Set the sender and recipients
+	if err := conn.Mail(from.Address); err != nil {
+		return fmt.Errorf("failed to set the sender: %v", err)
+	}
+	zap.S().Debugln("SMTP set MAIL FROM", "address", from.Address)
+	for _, recipient := range to {
+		if err := conn.Rcpt(recipient.Address); err != nil {
+			return fmt.Errorf("failed to set the recipient: %v", err)
+		}
+		zap.S().Debugln("SMTP set RCPT TO", "address", recipient.Address)
+	}
+
+	// Send the email body
+	wc, err := conn.Data()
+	if err != nil {
+		return fmt.Errorf("failed to open data writer: %v", err)
+	}
+	zap.S().Debugln("SMTP DATA command started")
+
+	if _, err := wc.Write(msg); err != nil {
+		return fmt.Errorf("failed to write email body: %v", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("failed to finish writing email body: %v", err)
+	}
+	zap.S().Debugln("SMTP wrote DATA")
+
+	// Close the connection
+	closeConn := conn.Close
+	if s.quit {
+		closeConn = conn.Quit
+	}
+	if err := closeConn(); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	zap.S().Debugln("SMTP email accepted")
+
+	return nil
+}
+
+// Headers and body of a notification email
+//
+// Headers may only contain ASCII, so the subject is encoded if necessary.
+// Bodies are sent as UTF-8.
+func (data *emailMessage) format() []byte {
+	to := make([]string, len(data.to))
+	for i, recipient := range data.to {
+		to[i] = recipient.String()
+	}
+
+	return []byte(fmt.Sprintf(
+		"To: %s\r\nFrom: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		strings.Join(to, ", "),
+		data.from.String(),
+		mime.QEncoding.Encode("utf-8", data.subject),
+		time.Now().Format(time.RFC1123Z),
+		strings.ReplaceAll(strings.ReplaceAll(data.body, "\r\n", "\n"), "\n", "\r\n"),
+	))
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"net/mail"
-	"net/smtp"
 	"net/textproto"
 	"strings"
 	"time"
@@ -28,8 +27,6 @@ type emailData struct {
 
 type emailPingMonitor struct {
 	name     string
-	host     string
-	key      string
 	interval int
 
 	smtp_host              string
@@ -61,19 +58,11 @@ func (m *emailPingMonitor) Type() string {
 	return "email_ping"
 }
 
-func (m *emailPingMonitor) HostURL() string {
-	return m.host
-}
-
-func (m *emailPingMonitor) Key() string {
-	return m.key
-}
-
 func (m *emailPingMonitor) Interval() int {
 	return m.interval
 }
 
-func (m *emailPingMonitor) Run() (monitorStatus, string, int, error) {
+func (m *emailPingMonitor) Run() (monitorStatus, string, int, map[string]float64, error) {
 	// Compose the email
 	from := mail.Address{Name: "", Address: m.smtp_sender_address}
 	to := mail.Address{Name: "", Address: m.smtp_recipient_address}
@@ -93,7 +82,7 @@ func (m *emailPingMonitor) Run() (monitorStatus, string, int, error) {
 	if message, ping, err := m.receive_email(data, false); err != nil {
 		message = fmt.Sprintf("error cleaning old responses: %v", message)
 
-		return StatusDown, message, ping, errors.New(message)
+		return StatusDown, message, ping, nil, errors.New(message)
 	}
 	zap.S().Debugln("Cleaned old responses")
 
@@ -101,79 +90,32 @@ func (m *emailPingMonitor) Run() (monitorStatus, string, int, error) {
 	// Send email to PingPong service
 	zap.S().Debugln("Sending email...")
 	if message, ping, err := m.send_email(data); err != nil {
-		return StatusDown, message, ping, err
+		return StatusDown, message, ping, nil, err
 	}
 
 	// Receive response from PingPong service
 	zap.S().Debugln("Waiting for response...")
 	if message, ping, err := m.receive_email(data, true); err != nil {
-		return StatusDown, message, ping, err
+		return StatusDown, message, ping, nil, err
 	}
 	end := time.Now()
 
-	return StatusUp, "OK", int(end.Sub(start).Seconds()), nil
-}
-
-func (m *emailPingMonitor) send_email(data *emailData) (string, int, error) {
-	// Connect to the SMTP server
-	smtpAddress := fmt.Sprintf("%s:%d", m.smtp_host, m.smtp_port)
-	auth := smtp.PlainAuth("", m.smtp_username, m.smtp_password, m.smtp_host)
-	conn, err := smtp.Dial(smtpAddress)
-	if err != nil {
-		message := fmt.Sprintf("failed to connect to SMTP server: %v", err)
-
-		return message, 0, errors.New(message)
-	}
-	zap.S().Debugw("SMTP dialed", "address", smtpAddress)
-
-	// STARTTLS
-	if ok, _ := conn.Extension("STARTTLS"); ok {
-		config := &tls.Config{
-			ServerName: m.smtp_host,
-		}
-		if err = conn.StartTLS(config); err != nil {
-			message := fmt.Sprintf("failed to starttls: %v", err)
-
-			return message, 0, errors.New(message)
-		}
-		zap.S().Debugln("SMTP STARTTLS completed")
-	} else if m.smtp_force_tls {
-		zap.S().Debugln("SMTP STARTTLS extension forced but no support")
-		return "SMTP STARTTLS extension forced but no support", 0, errors.New("STARTTLS extension forced but no support")
-	} else {
-		zap.S().Debugln("SMTP continuing with unencrypted connection!")
-	}
-
-	// Authenticate
-	if err := conn.Auth(auth); err != nil {
-		message := fmt.Sprintf("SMTP authentication failed: %v", err)
-
-		return message, 0, errors.New(message)
-	}
-	zap.S().Debugln("SMTP authenticated")
-
-	// Set the sender and recipient
-	if err := conn.Mail(data.from.Address); err != nil {
-		message := fmt.Sprintf("failed to set the sender: %v", err)
-
-		return message, 0, errors.New(message)
-	}
-	zap.S().Debugln("SMTP set MAIL FROM", "address", data.from.Address)
-	if err := conn.Rcpt(data.to.Address); err != nil {
-		message := fmt.Sprintf("failed to set the recipient: %v", err)
-
-		return message, 0, errors.New(message)
+	values := map[string]float64{
+		"round_trip_seconds": end.Sub(start).Seconds(),
 	}
-	zap.S().Debugln("SMTP set RCPT TO", "address", data.to.Address)
 
-	// Send the email body
-	wc, err := conn.Data()
-	if err != nil {
-		message := fmt.Sprintf("failed to open data writer: %v", err)
+	return StatusUp, "OK", int(end.Sub(start).Seconds()), values, nil
+}
 
-		return message, 0, errors.New(message)
+func (m *emailPingMonitor) send_email(data *emailData) (string, int, error) {
+	server := &smtpServer{
+		host:        m.smtp_host,
+		port:        m.smtp_port,
+		forceTLS:    m.smtp_force_tls,
+		username:    m.smtp_username,
+		password:    m.smtp_password,
+		requireAuth: true,
 	}
-	zap.S().Debugln("SMTP DATA command started")
 
 	msg := []byte(fmt.Sprintf(
 		"To: %s\r\nFrom: %s\r\nSubject: %s\r\n\r\n%s",
@@ -182,25 +124,14 @@ func (m *emailPingMonitor) send_email(data *emailData) (string, int, error) {
 		data.subject,
 		data.body,
 	))
-	if _, err := wc.Write(msg); err != nil {
-		message := fmt.Sprintf("failed to write email body: %v", err)
-
-		return message, 0, errors.New(message)
-	}
-	if err := wc.Close(); err != nil {
-		message := fmt.Sprintf("failed to finish writing email body: %v", err)
-
-		return message, 0, errors.New(message)
-	}
-	zap.S().Debugln("SMTP wrote DATA")
-
-	// Close the connection
-	if err := conn.Close(); err != nil {
-		message := fmt.Sprintf("failed to send email: %v", err)
+	if err := server.send(data.from, []*mail.Address{data.to}, msg); err != nil {
+		message := err.Error()
+		if errors.Is(err, errSTARTTLSUnsupported) {
+			message = "SMTP " + message
+		}
 
-		return message, 0, errors.New(message)
+		return message, 0, err
 	}
-	zap.S().Debugln("SMTP email accepted")
 
 	return "", 0, nil
 }
@@ -361,7 +292,7 @@ func (m *emailPingMonitor) receive_email(data *emailData, shouldWaitForResponse
 }
 
 // Setup a monitor of type 'email_ping'
-func setupEmailPingMonitor(host string, monitor *config.Monitor) *emailPingMonitor {
+func setupEmailPingMonitor(monitor *config.Monitor) *emailPingMonitor {
 	//? SMTP
 	// region parameter checks
 	if monitor.SMTPHost == "" {
@@ -481,9 +412,7 @@ func setupEmailPingMonitor(host string, monitor *config.Monitor) *emailPingMonit
 
 	return &emailPingMonitor{
 		name:     monitor.Name,
-		host:     host,
 		interval: monitor.Interval,
-		key:      monitor.Key,
 
 		smtp_host:              monitor.SMTPHost,
 		smtp_port:              monitor.SMTPPort,
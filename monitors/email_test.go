@@ -0,0 +1,183 @@
+package monitors
+
+import (
+	"bufio"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Fake SMTP server recording the commands and message of every session
+type fakeSMTP struct {
+	port int
+
+	mu       sync.Mutex
+	commands []string
+	message  string
+	done     chan struct{}
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &fakeSMTP{port: listener.Addr().(*net.TCPAddr).Port, done: make(chan struct{})}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer close(s.done)
+		defer conn.Close()
+		s.serve(conn)
+	}()
+
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	conn.Write([]byte("220 fake ESMTP\r\n"))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.Fields(line + " ")[0])
+		s.mu.Lock()
+		s.commands = append(s.commands, command)
+		s.mu.Unlock()
+
+		switch command {
+		case "EHLO":
+			conn.Write([]byte("250-fake\r\n250 AUTH PLAIN\r\n"))
+		case "AUTH":
+			conn.Write([]byte("235 Authenticated\r\n"))
+		case "DATA":
+			conn.Write([]byte("354 Go ahead\r\n"))
+			var message strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				message.WriteString(line)
+			}
+			s.mu.Lock()
+			s.message = message.String()
+			s.mu.Unlock()
+			conn.Write([]byte("250 Queued\r\n"))
+		case "QUIT":
+			conn.Write([]byte("221 Bye\r\n"))
+			return
+		default:
+			conn.Write([]byte("250 OK\r\n"))
+		}
+	}
+}
+
+// Commands and message of the session once it ended
+func (s *fakeSMTP) session(t *testing.T) ([]string, string) {
+	t.Helper()
+
+	select {
+	case <-s.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("SMTP session did not end")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands, s.message
+}
+
+func TestEmailNotifierSkipsAuthWithoutUsername(t *testing.T) {
+	fake := startFakeSMTP(t)
+	server := &smtpServer{host: "127.0.0.1", port: fake.port, quit: true, timeout: time.Second}
+	data := &emailMessage{
+		from:    &mail.Address{Name: "Robot", Address: "robot@example.com"},
+		to:      []*mail.Address{{Address: "ops@example.com"}, {Address: "oncall@example.com"}},
+		subject: "Festplatte über 95%",
+		body:    "Line 1\nLine 2",
+	}
+
+	if err := server.send(data.from, data.to, data.format()); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	commands, message := fake.session(t)
+	if got := strings.Join(commands, " "); got != "EHLO MAIL RCPT RCPT DATA QUIT" {
+		t.Errorf("commands = %q, want no AUTH and a QUIT", got)
+	}
+	for _, header := range []string{
+		"To: <ops@example.com>, <oncall@example.com>\r\n",
+		"From: \"Robot\" <robot@example.com>\r\n",
+		"Subject: =?utf-8?q?Festplatte_=C3=BCber_95%?=\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"Date: ",
+	} {
+		if !strings.Contains(message, header) {
+			t.Errorf("message misses %q:\n%v", header, message)
+		}
+	}
+	if !strings.HasSuffix(message, "\r\n\r\nLine 1\r\nLine 2\r\n") {
+		t.Errorf("unexpected body:\n%q", message)
+	}
+}
+
+func TestEmailPingKeepsItsSMTPBehavior(t *testing.T) {
+	fake := startFakeSMTP(t)
+	m := &emailPingMonitor{
+		smtp_host: "127.0.0.1",
+		smtp_port: fake.port,
+	}
+	data := &emailData{
+		from:    &mail.Address{Address: "ping@example.com"},
+		to:      &mail.Address{Address: "check@ping-pong.email"},
+		subject: "Ping",
+		body:    "This is a test email",
+	}
+
+	if message, _, err := m.send_email(data); err != nil {
+		t.Fatalf("send failed: %v (%v)", err, message)
+	}
+
+	// Authenticates even without a username and only closes the connection
+	commands, message := fake.session(t)
+	if got := strings.Join(commands, " "); got != "EHLO AUTH MAIL RCPT DATA" {
+		t.Errorf("commands = %q, want AUTH and no QUIT", got)
+	}
+	want := "To: <check@ping-pong.email>\r\nFrom: <ping@example.com>\r\nSubject: Ping\r\n\r\nThis is a test email\r\n"
+	if message != want {
+		t.Errorf("message = %q, want %q", message, want)
+	}
+}
+
+func TestEmailPingReportsUnsupportedSTARTTLS(t *testing.T) {
+	fake := startFakeSMTP(t)
+	m := &emailPingMonitor{
+		smtp_host:      "127.0.0.1",
+		smtp_port:      fake.port,
+		smtp_force_tls: true,
+	}
+	data := &emailData{
+		from: &mail.Address{Address: "ping@example.com"},
+		to:   &mail.Address{Address: "check@ping-pong.email"},
+	}
+
+	message, _, err := m.send_email(data)
+	if err == nil {
+		t.Fatal("expected STARTTLS to be required")
+	}
+	if message != "SMTP STARTTLS extension forced but no support" || err.Error() != "STARTTLS extension forced but no support" {
+		t.Errorf("message = %q, err = %v", message, err)
+	}
+}
@@ -0,0 +1,204 @@
+package monitors
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Default seconds between two refreshes of firing alerts
+const defaultAlertRefreshInterval = 60
+
+// Name of all alerts sent to Alertmanager
+const alertName = "UptimeRobotMonitorDown"
+
+// Sends alerts to Prometheus Alertmanager while a monitor is down
+//
+// Alerts are labeled with `alertname`, `node`, `monitor`, `type` and the
+// labels of the host. A background goroutine refreshes firing alerts before
+// they expire, independent of the interval of the monitor. Once the monitor
+// recovers, a resolved alert is sent until Alertmanager accepted it. Like
+// incidents, the first result of a monitor that is up resolves any alert that
+// might still be firing from before a restart.
+type alertmanagerBackend struct {
+	labels   map[string]string
+	username string
+	password string
+	// Time between two refreshes of firing alerts
+	refreshInterval time.Duration
+
+	mu sync.Mutex
+	// Alerts that are firing or still have to be resolved, by dedup key
+	alerts map[string]*alertmanagerAlert
+	// Dedup keys of monitors whose alert was resolved
+	resolved map[string]bool
+}
+
+// Alert as accepted by the Alertmanager API v2
+type alertmanagerAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+func (b *alertmanagerBackend) push(h *host, r *Result) error {
+	key := incidentDedupKey(r)
+	n := newNotification(r)
+
+	b.mu.Lock()
+	if r.Status == StatusUp && b.resolved[key] {
+		b.mu.Unlock()
+		return nil
+	}
+	delete(b.resolved, key)
+
+	alert, ok := b.alerts[key]
+	if !ok {
+		labels := make(map[string]string, len(b.labels)+4)
+		for name, value := range b.labels {
+			labels[name] = value
+		}
+		//? Applied last, as they identify the alert
+		labels["alertname"] = alertName
+		labels["node"] = r.NodeName
+		labels["monitor"] = r.Monitor
+		labels["type"] = r.Type
+
+		alert = &alertmanagerAlert{
+			Labels:   labels,
+			StartsAt: r.Time,
+		}
+		b.alerts[key] = alert
+	}
+
+	alert.Annotations = map[string]string{
+		"summary":     n.title,
+		"description": n.message,
+	}
+	alert.GeneratorURL = r.ClickURL
+	if r.Status == StatusUp {
+		alert.EndsAt = r.Time
+	} else {
+		alert.EndsAt = time.Time{}
+	}
+	b.mu.Unlock()
+
+	return b.send(h)
+}
+
+// Send all firing and resolved alerts, extending the firing ones
+func (b *alertmanagerBackend) send(h *host) error {
+	b.mu.Lock()
+	now := time.Now()
+	alerts := make([]alertmanagerAlert, 0, len(b.alerts))
+	// End of the resolved alerts that are sent
+	resolving := make(map[string]time.Time)
+	for key, alert := range b.alerts {
+		a := *alert
+		if a.EndsAt.IsZero() {
+			//? Leave enough time for a failed refresh to be retried
+			a.EndsAt = now.Add(3 * b.refreshInterval)
+		} else {
+			resolving[key] = a.EndsAt
+		}
+		alerts = append(alerts, a)
+	}
+	b.mu.Unlock()
+
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	err = h.tryURLs(func(hostURL string) error {
+		//? We already ensure that host ends with a trailing / in setupHost
+		req, err := http.NewRequest(http.MethodPost, hostURL+"api/v2/alerts", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if b.username != "" {
+			req.SetBasicAuth(b.username, b.password)
+		}
+
+		zap.S().Debugw("Sending alerts to Alertmanager",
+			"host", hostURL,
+			"alerts", len(alerts),
+		)
+
+		_, err = doPushRequest(req, h.timeout, &h.stats)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Resolved alerts were delivered and no longer have to be sent, unless
+	// they changed in the meantime
+	b.mu.Lock()
+	for key, endsAt := range resolving {
+		if alert, ok := b.alerts[key]; ok && alert.EndsAt.Equal(endsAt) {
+			delete(b.alerts, key)
+			b.resolved[key] = true
+		}
+	}
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Refresh firing alerts periodically so they do not expire
+//
+// Should be called in a go-routine
+func (b *alertmanagerBackend) refresh(h *host) {
+	ticker := time.NewTicker(b.refreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := b.send(h); err != nil {
+			zap.S().Warnw("Error refreshing alerts",
+				"host", h.name,
+				"error", err,
+			)
+		}
+	}
+}
+
+// Setup the backend of a host of type 'alertmanager'
+func setupAlertmanagerBackend(h *host, hostConfig *config.Host) *alertmanagerBackend {
+	b := &alertmanagerBackend{
+		labels:          hostConfig.Labels,
+		username:        hostConfig.Username,
+		password:        hostConfig.Password,
+		refreshInterval: time.Duration(hostConfig.RefreshInterval) * time.Second,
+		alerts:          make(map[string]*alertmanagerAlert),
+		resolved:        make(map[string]bool),
+	}
+	if hostConfig.RefreshInterval == 0 {
+		b.refreshInterval = defaultAlertRefreshInterval * time.Second
+	}
+
+	for _, name := range []string{"alertname", "node", "monitor", "type"} {
+		if _, ok := b.labels[name]; ok {
+			zap.S().Panicw("Reserved alert label",
+				"name", hostConfig.Name,
+				"label", name,
+			)
+		}
+	}
+
+	go b.refresh(h)
+
+	return b
+}
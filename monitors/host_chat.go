@@ -0,0 +1,117 @@
+package monitors
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Name shown as author of chat messages where supported
+const chatUsername = "Uptime-Robot"
+
+// Posts a message to the incoming webhook of a chat service whenever a monitor
+// changes its status
+//
+// Supported services are Slack, Discord, Microsoft Teams and Mattermost. The URL
+// of the host is the webhook URL as provided by the service.
+type chatBackend struct {
+	// Same as the type of the host
+	service string
+}
+
+func (b *chatBackend) push(h *host, r *Result) error {
+	body, err := json.Marshal(b.payload(newNotification(r), r))
+	if err != nil {
+		return err
+	}
+
+	return h.tryURLs(func(hostURL string) error {
+		req, err := http.NewRequest(http.MethodPost, hostURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		zap.S().Debugw("Sending chat notification",
+			"host", h.name,
+			"service", b.service,
+			"status", r.Status,
+			"previous_status", r.PreviousStatus,
+		)
+
+		_, err = doPushRequest(req, h.timeout, &h.stats)
+		return err
+	})
+}
+
+// Build the JSON payload of the webhook of the service
+func (b *chatBackend) payload(n notification, r *Result) any {
+	switch b.service {
+	case "discord":
+		embed := map[string]any{
+			"title":     n.title,
+			"color":     n.color,
+			"timestamp": r.Time,
+		}
+		if n.message != "" {
+			embed["description"] = n.message
+		}
+		if n.previous != "" {
+			embed["footer"] = map[string]string{"text": n.previous}
+		}
+
+		return map[string]any{
+			"username": chatUsername,
+			"embeds":   []any{embed},
+		}
+	case "teams":
+		card := map[string]any{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    n.title,
+			"title":      n.title,
+			"themeColor": n.hexColor(),
+		}
+		text := n.message
+		if n.previous != "" {
+			text += "\n\n" + n.previous
+		}
+		if text != "" {
+			card["text"] = text
+		}
+
+		return card
+	default:
+		// Mattermost accepts the same payload as Slack
+		attachment := map[string]any{
+			"fallback": n.text(),
+			"color":    "#" + n.hexColor(),
+			"title":    n.title,
+			"text":     n.message,
+			"ts":       r.Time.Unix(),
+		}
+		if n.previous != "" {
+			attachment["footer"] = n.previous
+		}
+
+		payload := map[string]any{
+			"attachments": []any{attachment},
+		}
+		if b.service == "mattermost" {
+			payload["username"] = chatUsername
+		}
+
+		return payload
+	}
+}
+
+// Setup the backend of a host of type 'slack', 'discord', 'teams' or
+// 'mattermost'
+func setupChatBackend(hostConfig *config.Host, service string) *chatBackend {
+	return &chatBackend{
+		service: service,
+	}
+}
@@ -0,0 +1,225 @@
+package monitors
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Default number of emails sent per hour by hosts of type 'email'
+const defaultEmailRateLimit = 20
+
+// Sends an email to a list of recipients whenever a monitor changes its status
+//
+// Subject and body are rendered from templates of the host, defaulting to a
+// short summary of the change. At most `rate_limit` emails are sent per hour,
+// further changes are dropped and mentioned in the next email sent.
+type emailBackend struct {
+	from       *mail.Address
+	recipients []*mail.Address
+	forceTLS   bool
+	username   string
+	password   string
+
+	// nil to use the default summary
+	subject *template.Template
+	body    *template.Template
+
+	// Maximum number of emails per hour
+	rateLimit int
+
+	mu sync.Mutex
+	// Times emails were sent within the last hour
+	sent []time.Time
+	// Number of changes dropped since the last email
+	suppressed int
+}
+
+func (b *emailBackend) push(h *host, r *Result) error {
+	slot, ok := b.reserve()
+	if !ok {
+		zap.S().Warnw("Email rate limit exceeded, dropping notification",
+			"host", h.name,
+			"monitor", r.Monitor,
+			"status", r.Status,
+			"rate_limit", b.rateLimit,
+		)
+		return nil
+	}
+
+	n := newNotification(r)
+	subject, body := n.title, n.text()
+
+	var err error
+	if b.subject != nil {
+		if subject, err = renderTemplate(b.subject, r); err != nil {
+			b.release(slot)
+			return err
+		}
+	}
+	if b.body != nil {
+		if body, err = renderTemplate(b.body, r); err != nil {
+			b.release(slot)
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	suppressed := b.suppressed
+	b.suppressed = 0
+	b.mu.Unlock()
+	if suppressed > 0 {
+		body += fmt.Sprintf("\n\n%v notifications were dropped due to the rate limit.", suppressed)
+	}
+
+	data := &emailMessage{
+		from:    b.from,
+		to:      b.recipients,
+		subject: subject,
+		body:    body,
+	}
+
+	err = h.tryURLs(func(hostURL string) error {
+		serverUrl, err := url.Parse(hostURL)
+		if err != nil {
+			return err
+		}
+
+		port := 587
+		if serverUrl.Port() != "" {
+			if port, err = strconv.Atoi(serverUrl.Port()); err != nil {
+				return err
+			}
+		}
+
+		server := &smtpServer{
+			host:     serverUrl.Hostname(),
+			port:     port,
+			forceTLS: b.forceTLS,
+			username: b.username,
+			password: b.password,
+			quit:     true,
+			timeout:  h.timeout,
+		}
+
+		zap.S().Debugw("Sending email notification",
+			"host", h.name,
+			"server", serverUrl.Host,
+			"recipients", len(b.recipients),
+			"subject", subject,
+		)
+
+		start := time.Now()
+		err = server.send(data.from, data.to, data.format())
+		h.stats.record(time.Since(start), err)
+
+		return err
+	})
+	if err != nil {
+		// Mention the dropped notifications in the next email instead
+		b.mu.Lock()
+		b.suppressed += suppressed
+		b.mu.Unlock()
+		b.release(slot)
+	}
+
+	return err
+}
+
+// Whether another email may be sent according to the rate limit, reserving a
+// slot for it if so
+//
+// The slot has to be released if the email could not be sent, so only
+// successfully sent emails count against the rate limit.
+func (b *emailBackend) reserve() (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hourAgo := time.Now().Add(-time.Hour)
+	for len(b.sent) > 0 && b.sent[0].Before(hourAgo) {
+		b.sent = b.sent[1:]
+	}
+
+	if len(b.sent) >= b.rateLimit {
+		b.suppressed++
+		return time.Time{}, false
+	}
+
+	slot := time.Now()
+	b.sent = append(b.sent, slot)
+	return slot, true
+}
+
+// Release a slot reserved for an email that was not sent
+func (b *emailBackend) release(slot time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, sent := range b.sent {
+		if sent.Equal(slot) {
+			b.sent = append(b.sent[:i], b.sent[i+1:]...)
+			return
+		}
+	}
+}
+
+// Setup the backend of a host of type 'email'
+func setupEmailBackend(hostConfig *config.Host) *emailBackend {
+	from, err := mail.ParseAddress(hostConfig.From)
+	if err != nil {
+		zap.S().Panicw("Invalid paramter for host",
+			"name", hostConfig.Name,
+			"type", hostConfig.Type,
+			"paramter", "from",
+			"error", err,
+		)
+	}
+
+	if len(hostConfig.Recipients) == 0 {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"type", hostConfig.Type,
+			"paramter", "recipients",
+		)
+	}
+
+	b := &emailBackend{
+		from:      from,
+		forceTLS:  hostConfig.ForceTLS,
+		username:  hostConfig.Username,
+		password:  hostConfig.Password,
+		rateLimit: hostConfig.RateLimit,
+	}
+	if b.rateLimit == 0 {
+		b.rateLimit = defaultEmailRateLimit
+	}
+
+	for _, recipient := range hostConfig.Recipients {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			zap.S().Panicw("Invalid paramter for host",
+				"name", hostConfig.Name,
+				"type", hostConfig.Type,
+				"paramter", "recipients",
+				"error", err,
+			)
+		}
+		b.recipients = append(b.recipients, address)
+	}
+
+	if hostConfig.Subject != "" {
+		b.subject = parseHostTemplate(hostConfig, "subject", hostConfig.Subject)
+	}
+	if hostConfig.Body != "" {
+		b.body = parseHostTemplate(hostConfig, "body", hostConfig.Body)
+	}
+
+	return b
+}
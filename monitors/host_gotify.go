@@ -0,0 +1,102 @@
+package monitors
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Priorities of Gotify messages by status
+//
+// Gotify's Android app shows a pop-up for priorities of 8 and above.
+const (
+	gotifyPriorityUp   = 4
+	gotifyPriorityDown = 8
+)
+
+// Sends push notifications using Gotify (https://gotify.net) whenever a monitor
+// changes its status
+//
+// Down messages have a high priority. Gotify does not know tags, so they are
+// appended to the message. The click URL of the monitor is opened when tapping
+// the notification.
+type gotifyBackend struct {
+	// Token of the application messages are sent as
+	token string
+}
+
+// Message as accepted by Gotify's message API
+type gotifyMessage struct {
+	Title    string         `json:"title"`
+	Message  string         `json:"message"`
+	Priority int            `json:"priority"`
+	Extras   map[string]any `json:"extras,omitempty"`
+}
+
+func (b *gotifyBackend) push(h *host, r *Result) error {
+	n := newNotification(r)
+
+	message := gotifyMessage{
+		Title:    n.title,
+		Message:  n.details(),
+		Priority: gotifyPriorityDown,
+	}
+	if r.Status == StatusUp {
+		message.Priority = gotifyPriorityUp
+	}
+	if len(r.Tags) > 0 {
+		message.Message = strings.TrimPrefix(message.Message+"\nTags: "+strings.Join(r.Tags, ", "), "\n")
+	}
+	if message.Message == "" {
+		message.Message = n.title
+	}
+	if r.ClickURL != "" {
+		message.Extras = map[string]any{
+			"client::notification": map[string]any{
+				"click": map[string]string{"url": r.ClickURL},
+			},
+		}
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	return h.tryURLs(func(hostURL string) error {
+		//? We already ensure that host ends with a trailing / in setupHost
+		req, err := http.NewRequest(http.MethodPost, hostURL+"message", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Gotify-Key", b.token)
+
+		zap.S().Debugw("Sending Gotify notification",
+			"host", h.name,
+			"status", r.Status,
+			"priority", message.Priority,
+		)
+
+		_, err = doPushRequest(req, h.timeout, &h.stats)
+		return err
+	})
+}
+
+// Setup the backend of a host of type 'gotify'
+func setupGotifyBackend(hostConfig *config.Host) *gotifyBackend {
+	if hostConfig.Token == "" {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"paramter", "token",
+		)
+	}
+
+	return &gotifyBackend{
+		token: hostConfig.Token,
+	}
+}
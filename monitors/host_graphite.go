@@ -0,0 +1,166 @@
+package monitors
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Default path of metrics sent to Graphite
+const defaultGraphitePath = "uptime_robot.{node}.{monitor}.{metric}"
+
+// Writes results using the plaintext protocol of Graphite (`path value
+// timestamp` lines over TCP)
+//
+// Every result produces the metrics `up` (0 or 1), `duration_seconds` and one
+// metric per value of the monitor. Their paths are built from a template that
+// can contain the placeholders `{node}`, `{monitor}`, `{type}`, `{key}` and
+// `{metric}`.
+//
+// Lines are buffered while the relay is unavailable and sent once the
+// connection could be re-established.
+type graphiteBackend struct {
+	// Template of metric paths
+	path string
+
+	batcher *batcher[string]
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (b *graphiteBackend) push(h *host, r *Result) error {
+	b.batcher.add(b.lines(r)...)
+	return nil
+}
+
+// Format all metrics of a result, one per line
+func (b *graphiteBackend) lines(r *Result) []string {
+	values := [][2]string{
+		{"up", strconv.FormatFloat(statusValue(r.Status), 'f', -1, 64)},
+		{"duration_seconds", strconv.FormatFloat(r.Duration.Seconds(), 'f', -1, 64)},
+	}
+
+	names := make([]string, 0, len(r.Values))
+	for name := range r.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values = append(values, [2]string{name, strconv.FormatFloat(r.Values[name], 'f', -1, 64)})
+	}
+
+	//? Graphite allows the same characters in a path segment as StatsD
+	replacer := strings.NewReplacer(
+		"{node}", statsdName(r.NodeName),
+		"{monitor}", statsdName(r.Monitor),
+		"{type}", statsdName(r.Type),
+		"{key}", statsdName(r.Key),
+	)
+	path := replacer.Replace(b.path)
+	timestamp := strconv.FormatInt(r.Time.Unix(), 10)
+
+	lines := make([]string, len(values))
+	for i, value := range values {
+		lines[i] = strings.ReplaceAll(path, "{metric}", statsdName(value[0])) + " " + value[1] + " " + timestamp
+	}
+
+	return lines
+}
+
+// Write a batch of lines to host `h`, reconnecting if necessary
+func (b *graphiteBackend) send(h *host, lines []string) error {
+	data := []byte(strings.Join(lines, "\n") + "\n")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := time.Now()
+
+	// Reuse the existing connection, reconnecting if the relay closed it
+	if b.conn != nil {
+		if err := b.write(h, data); err == nil {
+			h.stats.record(time.Since(start), nil)
+			return nil
+		}
+	}
+
+	err := h.tryURLs(func(hostURL string) error {
+		if err := b.connect(h, hostURL); err != nil {
+			return err
+		}
+
+		return b.write(h, data)
+	})
+	h.stats.record(time.Since(start), err)
+
+	return err
+}
+
+// Connect to the relay at `hostURL`, replacing any existing connection
+//
+// Must be called with b.mu held
+func (b *graphiteBackend) connect(h *host, hostURL string) error {
+	if b.conn != nil {
+		b.conn.Close()
+		b.conn = nil
+	}
+
+	relayUrl, err := url.Parse(hostURL)
+	if err != nil {
+		return err
+	}
+
+	address := relayUrl.Host
+	if relayUrl.Port() == "" {
+		address = net.JoinHostPort(relayUrl.Hostname(), "2003")
+	}
+
+	zap.S().Debugw("Connecting to Graphite",
+		"host", h.name,
+		"address", address,
+	)
+
+	b.conn, err = net.DialTimeout("tcp", address, h.timeout)
+	return err
+}
+
+// Write data to the current connection, closing it on errors
+//
+// Must be called with b.mu held
+func (b *graphiteBackend) write(h *host, data []byte) error {
+	b.conn.SetWriteDeadline(time.Now().Add(h.timeout))
+	if _, err := b.conn.Write(data); err != nil {
+		b.conn.Close()
+		b.conn = nil
+		return err
+	}
+
+	return nil
+}
+
+// Setup the backend of a host of type 'graphite'
+func setupGraphiteBackend(h *host, hostConfig *config.Host) *graphiteBackend {
+	b := &graphiteBackend{
+		path: hostConfig.Prefix,
+	}
+	if b.path == "" {
+		b.path = defaultGraphitePath
+	}
+	if !strings.Contains(b.path, "{metric}") {
+		b.path = strings.TrimSuffix(b.path, ".") + ".{metric}"
+	}
+
+	b.batcher = startBatcher(h.name, hostConfig.FlushInterval, hostConfig.BatchSize, func(lines []string) error {
+		return b.send(h, lines)
+	})
+
+	return b
+}
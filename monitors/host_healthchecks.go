@@ -0,0 +1,76 @@
+package monitors
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Pushes results using the ping API of Healthchecks (https://healthchecks.io)
+//
+// The start of every run is signaled using `/start` so Healthchecks can measure
+// its duration. Afterwards either a success or `/fail` is pushed with the
+// message as request body, so it shows up in the check's event log.
+type healthchecksBackend struct{}
+
+func (b *healthchecksBackend) start(h *host, r *Result) error {
+	return h.tryURLs(func(hostURL string) error {
+		return b.ping(h, hostURL, r, "/start", "")
+	})
+}
+
+func (b *healthchecksBackend) push(h *host, r *Result) error {
+	endpoint := ""
+	if r.Status != StatusUp {
+		endpoint = "/fail"
+	}
+
+	return h.tryURLs(func(hostURL string) error {
+		return b.ping(h, hostURL, r, endpoint, r.Message)
+	})
+}
+
+// Send a single ping to `endpoint` of the check identified by the key using
+// one URL of host `h`
+//
+// The key is either the UUID of a check or `{ping key}/{slug}`.
+func (b *healthchecksBackend) ping(h *host, hostURL string, r *Result, endpoint string, body string) error {
+	// Parse base URL
+	pingUrl, err := url.Parse(hostURL)
+	if err != nil {
+		return err
+	}
+
+	//? We already ensure that host ends with a trailing / in setupHost
+	pingUrl.Path += r.Key + endpoint
+
+	// Allows Healthchecks to match start and finish of concurrent runs
+	params := url.Values{}
+	params.Add("rid", r.RunID)
+	pingUrl.RawQuery = params.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, pingUrl.String(), strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	zap.S().Debugw("Pinging Healthchecks",
+		"host", hostURL,
+		"key", r.Key,
+		"status", r.Status,
+		"message", r.Message,
+		"url", req.URL.String(),
+	)
+
+	_, err = doPushRequest(req, h.timeout, &h.stats)
+	return err
+}
+
+// Setup the backend of a host of type 'healthchecks'
+func setupHealthchecksBackend(hostConfig *config.Host) *healthchecksBackend {
+	return &healthchecksBackend{}
+}
@@ -0,0 +1,92 @@
+package monitors
+
+import (
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Pings plain URLs of a monitor, e.g. of a dead man's switch
+//
+// The heartbeat URL is requested after every run that is up, the failure URL
+// after every run that is down or failed, regardless of the monitor's push
+// mode. Both URLs are templates rendered with the result of the run. The
+// backend belongs to a host created for each monitor that sets either URL, so
+// pings show up in its metrics. Failed pings are not retried, the next run
+// pings again anyway.
+type heartbeatBackend struct {
+	// nil if not pinged
+	heartbeat *template.Template
+	failure   *template.Template
+}
+
+func (b *heartbeatBackend) push(h *host, r *Result) error {
+	tmpl := b.heartbeat
+	if r.Status != StatusUp {
+		tmpl = b.failure
+	}
+	if tmpl == nil {
+		return nil
+	}
+
+	pingURL, err := renderTemplate(tmpl, r)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, pingURL, nil)
+	if err != nil {
+		return err
+	}
+
+	zap.S().Debugw("Pinging heartbeat URL",
+		"host", h.name,
+		"status", r.Status,
+		"url", pingURL,
+	)
+
+	_, err = doPushRequest(req, h.timeout, &h.stats)
+	return err
+}
+
+// Setup the host pinging the heartbeat and failure URLs of a monitor, nil if
+// neither is set
+func setupHeartbeatHost(monitor *config.Monitor) *host {
+	if monitor.HeartbeatURL == "" && monitor.FailureURL == "" {
+		return nil
+	}
+
+	b := &heartbeatBackend{}
+	for _, ping := range []struct {
+		name string
+		text string
+		tmpl **template.Template
+	}{
+		{"heartbeat_url", monitor.HeartbeatURL, &b.heartbeat},
+		{"failure_url", monitor.FailureURL, &b.failure},
+	} {
+		if ping.text == "" {
+			continue
+		}
+
+		tmpl, err := template.New(ping.name).Funcs(templateFuncs).Parse(ping.text)
+		if err != nil {
+			zap.S().Panicw("Invalid template for monitor",
+				"name", monitor.Name,
+				"template", ping.name,
+				"error", err,
+			)
+		}
+		*ping.tmpl = tmpl
+	}
+
+	return &host{
+		name:     monitor.Name + " (heartbeat)",
+		hostType: "heartbeat",
+		backend:  b,
+		timeout:  defaultPushTimeout * time.Second,
+	}
+}
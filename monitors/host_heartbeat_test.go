@@ -0,0 +1,143 @@
+package monitors
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+)
+
+// Monitor that is never run, only identifies results
+type testMonitor struct {
+	name string
+}
+
+func (m *testMonitor) Name() string  { return m.name }
+func (m *testMonitor) Type() string  { return "test" }
+func (m *testMonitor) Interval() int { return 60 }
+func (m *testMonitor) Run() (monitorStatus, string, int, map[string]float64, error) {
+	return StatusUp, "", 0, nil, nil
+}
+
+// Starts a server sending the URI of every request it receives to the channel
+func startPingRecorder(t *testing.T) (string, chan string) {
+	t.Helper()
+
+	pings := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		pings <- req.URL.RequestURI()
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv.URL, pings
+}
+
+// Wait for the next ping, failing the test if none arrives
+func expectPing(t *testing.T, pings chan string, want string) {
+	t.Helper()
+
+	select {
+	case got := <-pings:
+		if got != want {
+			t.Errorf("pinged %q, want %q", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("no ping, want %q", want)
+	}
+}
+
+// Fail the test if a ping arrives within a short time
+func expectNoPing(t *testing.T, pings chan string) {
+	t.Helper()
+
+	select {
+	case got := <-pings:
+		t.Errorf("unexpected ping %q", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestHeartbeatPingsByStatus(t *testing.T) {
+	url, pings := startPingRecorder(t)
+	h := setupHeartbeatHost(&config.Monitor{
+		Name:         "Disk",
+		HeartbeatURL: url + "/ping/{{urlquery .Monitor}}",
+		FailureURL:   url + "/fail?msg={{urlquery .Message}}&node={{urlquery .NodeName}}",
+	})
+	if h.name != "Disk (heartbeat)" {
+		t.Errorf("name = %q, want Disk (heartbeat)", h.name)
+	}
+
+	r := &Result{NodeName: "node", Monitor: "Disk", Status: StatusUp, Message: "OK"}
+	if err := h.backend.push(h, r); err != nil {
+		t.Fatalf("heartbeat failed: %v", err)
+	}
+	expectPing(t, pings, "/ping/Disk")
+
+	r.Status = StatusDown
+	r.Message = "Exceeds threshold"
+	if err := h.backend.push(h, r); err != nil {
+		t.Fatalf("failure ping failed: %v", err)
+	}
+	expectPing(t, pings, "/fail?msg=Exceeds+threshold&node=node")
+}
+
+func TestHeartbeatSkipsUnsetURL(t *testing.T) {
+	url, pings := startPingRecorder(t)
+	h := setupHeartbeatHost(&config.Monitor{
+		Name:       "Disk",
+		FailureURL: url + "/fail",
+	})
+
+	if err := h.backend.push(h, &Result{Monitor: "Disk", Status: StatusUp}); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	expectNoPing(t, pings)
+
+	if setupHeartbeatHost(&config.Monitor{Name: "Disk"}) != nil {
+		t.Error("expected no host without any URL")
+	}
+}
+
+func TestHeartbeatIgnoresPushMode(t *testing.T) {
+	url, pings := startPingRecorder(t)
+	monitor := &config.Monitor{
+		Name:         "Disk",
+		Interval:     60,
+		PushMode:     string(PushModeOnChange),
+		Heartbeat:    600,
+		HeartbeatURL: url + "/ping",
+	}
+	d := setupHeartbeatDestination(monitor, setupHeartbeatHost(monitor))
+
+	// Unchanged results are pinged as well
+	m := &testMonitor{name: "Disk"}
+	for i := 0; i < 3; i++ {
+		d.push(m, Result{Monitor: "Disk", Status: StatusUp, Message: "OK"})
+		expectPing(t, pings, "/ping")
+	}
+}
+
+func TestFailedRunPingsFailureURL(t *testing.T) {
+	url, pings := startPingRecorder(t)
+	monitor := &config.Monitor{
+		Name:       "Disk",
+		Interval:   60,
+		FailureURL: url + "/fail?msg={{urlquery .Message}}",
+	}
+	hookURL, hookPings := startPingRecorder(t)
+	hook := setupHost(&config.Host{Name: "hook", Type: "webhook", URL: hookURL}, "node")
+
+	destinations := []*destination{
+		setupHeartbeatDestination(monitor, setupHeartbeatHost(monitor)),
+		{host: hook, state: setupPushState(monitor)},
+	}
+	pushFailedRun(&testMonitor{name: "Disk"}, Result{Monitor: "Disk"}, errors.New("timeout"), destinations, make([]<-chan struct{}, len(destinations)))
+
+	expectPing(t, pings, "/fail?msg=Error+running+monitor%3A+timeout")
+	// Hosts without a start of the run only receive runs that did not fail
+	expectNoPing(t, hookPings)
+}
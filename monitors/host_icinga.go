@@ -0,0 +1,242 @@
+package monitors
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Exit statuses of passive check results
+const (
+	checkOK       = 0
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+// Submits results as passive check results to Icinga 2
+//
+// URLs using http or https are sent to the `process-check-result` action of the
+// Icinga 2 REST API. URLs using nsca:// are submitted to an NSCA daemon instead,
+// which also works with legacy Nagios setups and can serve as fallback if the
+// API is unreachable.
+//
+// The key of a monitor is either the name of a service on the host named like
+// `node_name`, or `{host}!{service}`.
+type icingaBackend struct {
+	username string
+	password string
+
+	// Encryption method and password of NSCA
+	nscaEncryption int
+	nscaPassword   string
+}
+
+// Request body of the `process-check-result` action
+type icingaCheckResult struct {
+	Type            string            `json:"type"`
+	Filter          string            `json:"filter"`
+	FilterVars      map[string]string `json:"filter_vars"`
+	ExitStatus      int               `json:"exit_status"`
+	PluginOutput    string            `json:"plugin_output"`
+	PerformanceData []string          `json:"performance_data,omitempty"`
+	CheckSource     string            `json:"check_source"`
+	ExecutionStart  float64           `json:"execution_start"`
+	ExecutionEnd    float64           `json:"execution_end"`
+}
+
+func (b *icingaBackend) push(h *host, r *Result) error {
+	hostName, service := r.NodeName, r.Key
+	if i := strings.Index(r.Key, "!"); i >= 0 {
+		hostName, service = r.Key[:i], r.Key[i+1:]
+	}
+
+	exitStatus := checkExitStatus(r.Status)
+	perfData := checkPerformanceData(r.Values)
+
+	return h.tryURLs(func(hostURL string) error {
+		if strings.HasPrefix(hostURL, "nsca://") {
+			return b.submitNSCA(h, hostURL, hostName, service, exitStatus, r, perfData)
+		}
+
+		// Parse base URL
+		actionUrl, err := url.Parse(hostURL)
+		if err != nil {
+			return err
+		}
+
+		//? We already ensure that host ends with a trailing / in setupHost
+		actionUrl.Path += "v1/actions/process-check-result"
+
+		body, err := json.Marshal(icingaCheckResult{
+			Type:   "Service",
+			Filter: "host.name==host_name && service.name==service_name",
+			FilterVars: map[string]string{
+				"host_name":    hostName,
+				"service_name": service,
+			},
+			ExitStatus:      exitStatus,
+			PluginOutput:    r.Message,
+			PerformanceData: perfData,
+			CheckSource:     r.NodeName,
+			ExecutionStart:  float64(r.Time.UnixNano()) / 1e9,
+			ExecutionEnd:    float64(r.Time.Add(r.Duration).UnixNano()) / 1e9,
+		})
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequest(http.MethodPost, actionUrl.String(), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(b.username, b.password)
+
+		zap.S().Debugw("Submitting check result to Icinga",
+			"host", hostURL,
+			"icinga_host", hostName,
+			"service", service,
+			"exit_status", exitStatus,
+		)
+
+		_, err = doPushRequest(req, h.timeout, &h.stats)
+		return err
+	})
+}
+
+// Submit a check result to the NSCA daemon at `hostURL`
+func (b *icingaBackend) submitNSCA(h *host, hostURL string, hostName string, service string, exitStatus int, r *Result, perfData []string) error {
+	daemonUrl, err := url.Parse(hostURL)
+	if err != nil {
+		return err
+	}
+
+	address := daemonUrl.Host
+	if daemonUrl.Port() == "" {
+		address = net.JoinHostPort(daemonUrl.Hostname(), "5667")
+	}
+
+	output := r.Message
+	if len(perfData) > 0 {
+		output += "|" + strings.Join(perfData, " ")
+	}
+
+	zap.S().Debugw("Submitting check result via NSCA",
+		"host", h.name,
+		"address", address,
+		"icinga_host", hostName,
+		"service", service,
+		"exit_status", exitStatus,
+	)
+
+	start := time.Now()
+	err = sendNSCA(address, b.nscaEncryption, b.nscaPassword, nscaCheckResult{
+		host:       hostName,
+		service:    service,
+		exitStatus: exitStatus,
+		output:     output,
+	}, h.timeout)
+	h.stats.record(time.Since(start), err)
+
+	return err
+}
+
+// Map the status of a monitor to the exit status of a check
+//
+// Monitors only report up or down, there is no degraded status that could be
+// reported as WARNING. Any other status is reported as UNKNOWN, so a status
+// added later is never mistaken for OK.
+func checkExitStatus(status monitorStatus) int {
+	switch status {
+	case StatusUp:
+		return checkOK
+	case StatusDown:
+		return checkCritical
+	default:
+		return checkUnknown
+	}
+}
+
+// Format the values of a monitor as performance data in the Nagios plugin
+// format, e.g. `usage_percent=96%`
+func checkPerformanceData(values map[string]float64) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	perfData := make([]string, len(names))
+	for i, name := range names {
+		unit := ""
+		if strings.HasSuffix(name, "_percent") {
+			unit = "%"
+		} else if strings.HasSuffix(name, "_seconds") {
+			unit = "s"
+		}
+
+		label := name
+		if strings.ContainsAny(label, " '=") {
+			label = "'" + strings.ReplaceAll(label, "'", "''") + "'"
+		}
+
+		perfData[i] = label + "=" + strconv.FormatFloat(values[name], 'f', -1, 64) + unit
+	}
+
+	return perfData
+}
+
+// Setup the backend of a host of type 'icinga2'
+func setupIcingaBackend(hostConfig *config.Host) *icingaBackend {
+	// Only the REST API requires credentials, NSCA uses its own password
+	usesAPI := false
+	for _, hostURL := range append([]string{hostConfig.URL}, hostConfig.URLs...) {
+		if hostURL != "" && !strings.HasPrefix(hostURL, "nsca://") {
+			usesAPI = true
+		}
+	}
+	if usesAPI && hostConfig.Username == "" {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"type", hostConfig.Type,
+			"paramter", "username",
+		)
+	}
+	if usesAPI && hostConfig.Password == "" {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"type", hostConfig.Type,
+			"paramter", "password",
+		)
+	}
+
+	b := &icingaBackend{
+		username:     hostConfig.Username,
+		password:     hostConfig.Password,
+		nscaPassword: hostConfig.Secret,
+	}
+
+	switch hostConfig.Encryption {
+	case "", "xor":
+		b.nscaEncryption = nscaEncryptXOR
+	case "none":
+		b.nscaEncryption = nscaEncryptNone
+	default:
+		zap.S().Panicw("Unknown NSCA encryption",
+			"name", hostConfig.Name,
+			"encryption", hostConfig.Encryption,
+		)
+	}
+
+	return b
+}
@@ -0,0 +1,19 @@
+package monitors
+
+import "testing"
+
+func TestCheckExitStatus(t *testing.T) {
+	for _, tc := range []struct {
+		status monitorStatus
+		want   int
+	}{
+		{StatusUp, checkOK},
+		{StatusDown, checkCritical},
+		{"", checkUnknown},
+		{"degraded", checkUnknown},
+	} {
+		if got := checkExitStatus(tc.status); got != tc.want {
+			t.Errorf("checkExitStatus(%q) = %v, want %v", tc.status, got, tc.want)
+		}
+	}
+}
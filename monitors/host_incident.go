@@ -0,0 +1,239 @@
+package monitors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Default severities of incidents by service
+var defaultIncidentSeverities = map[string]string{
+	"pagerduty": "critical",
+	"opsgenie":  "P1",
+}
+
+// Opens an incident in PagerDuty or Opsgenie once a monitor goes down and
+// resolves it once the monitor recovers
+//
+// Incidents are identified by a dedup key that is stable per node and monitor,
+// so repeated triggers never create duplicates. The backend remembers whether
+// an incident is open for a monitor, so a trigger or resolve that failed is
+// retried with the next result. Resolving is idempotent, so the first result
+// of a monitor that is up resolves any incident left open before a restart.
+type incidentBackend struct {
+	// Same as the type of the host
+	service string
+	// Routing key (PagerDuty) or API key (Opsgenie)
+	token string
+	// Severity (PagerDuty) or priority (Opsgenie) of incidents
+	severity string
+
+	mu sync.Mutex
+	// Whether an incident is open by dedup key
+	open map[string]bool
+}
+
+// Event sent to PagerDuty's Events API v2
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Timestamp     string         `json:"timestamp"`
+	Component     string         `json:"component"`
+	Class         string         `json:"class"`
+	CustomDetails map[string]any `json:"custom_details"`
+}
+
+// Alert created using Opsgenie's Alert API
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description"`
+	Source      string            `json:"source"`
+	Priority    string            `json:"priority"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details"`
+}
+
+func (b *incidentBackend) push(h *host, r *Result) error {
+	dedupKey := incidentDedupKey(r)
+	trigger := r.Status != StatusUp
+
+	//? Unknown incidents are resolved as well, as one might still be open from
+	//? before a restart
+	b.mu.Lock()
+	open, ok := b.open[dedupKey]
+	b.mu.Unlock()
+	if ok && open == trigger {
+		return nil
+	}
+
+	//? The lock is not held during the request, so a slow service does not
+	//? block incidents of other monitors. Triggers and resolves are idempotent.
+
+	var err error
+	if b.service == "opsgenie" {
+		err = b.pushOpsgenie(h, r, dedupKey, trigger)
+	} else {
+		err = b.pushPagerDuty(h, r, dedupKey, trigger)
+	}
+	if err != nil {
+		return err
+	}
+
+	zap.S().Infow("Updated incident",
+		"host", h.name,
+		"service", b.service,
+		"dedup_key", dedupKey,
+		"open", trigger,
+	)
+	b.mu.Lock()
+	b.open[dedupKey] = trigger
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Trigger or resolve an incident using PagerDuty's Events API v2
+func (b *incidentBackend) pushPagerDuty(h *host, r *Result, dedupKey string, trigger bool) error {
+	event := pagerDutyEvent{
+		RoutingKey:  b.token,
+		EventAction: "resolve",
+		DedupKey:    dedupKey,
+	}
+	if trigger {
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{
+			Summary:       truncateMessage(newNotification(r).title+": "+r.Message, 1024),
+			Source:        r.NodeName,
+			Severity:      b.severity,
+			Timestamp:     r.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+			Component:     r.Monitor,
+			Class:         r.Type,
+			CustomDetails: incidentDetails(r),
+		}
+	}
+
+	return b.post(h, r, "v2/enqueue", event, nil)
+}
+
+// Create or close an alert using Opsgenie's Alert API
+func (b *incidentBackend) pushOpsgenie(h *host, r *Result, dedupKey string, trigger bool) error {
+	header := http.Header{}
+	header.Set("Authorization", "GenieKey "+b.token)
+
+	n := newNotification(r)
+	if !trigger {
+		return b.post(h, r, "v2/alerts/"+url.PathEscape(dedupKey)+"/close?identifierType=alias", map[string]string{
+			"source": r.NodeName,
+			"note":   n.text(),
+		}, header)
+	}
+
+	details := make(map[string]string)
+	for name, value := range incidentDetails(r) {
+		details[name] = fmt.Sprint(value)
+	}
+
+	return b.post(h, r, "v2/alerts", opsgenieAlert{
+		Message:     truncateMessage(n.title, 130),
+		Alias:       dedupKey,
+		Description: n.details(),
+		Source:      r.NodeName,
+		Priority:    b.severity,
+		Tags:        r.Tags,
+		Details:     details,
+	}, header)
+}
+
+// Post `body` as JSON to `path` of host `h`
+func (b *incidentBackend) post(h *host, r *Result, path string, body any, header http.Header) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	return h.tryURLs(func(hostURL string) error {
+		//? We already ensure that host ends with a trailing / in setupHost
+		req, err := http.NewRequest(http.MethodPost, hostURL+path, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		zap.S().Debugw("Sending incident update",
+			"host", hostURL,
+			"service", b.service,
+			"status", r.Status,
+			"url", req.URL.String(),
+		)
+
+		_, err = doPushRequest(req, h.timeout, &h.stats)
+		return err
+	})
+}
+
+// Key identifying the incident of a monitor, stable per node and monitor
+func incidentDedupKey(r *Result) string {
+	return "uptime-robot/" + r.NodeName + "/" + r.Monitor
+}
+
+// Details attached to an incident
+func incidentDetails(r *Result) map[string]any {
+	details := map[string]any{
+		"node":    r.NodeName,
+		"monitor": r.Monitor,
+		"type":    r.Type,
+		"status":  string(r.Status),
+		"message": r.Message,
+		"ping":    strconv.Itoa(r.Ping),
+	}
+	for name, value := range r.Values {
+		details[name] = value
+	}
+	if r.ClickURL != "" {
+		details["url"] = r.ClickURL
+	}
+
+	return details
+}
+
+// Setup the backend of a host of type 'pagerduty' or 'opsgenie'
+func setupIncidentBackend(hostConfig *config.Host, service string) *incidentBackend {
+	if hostConfig.Token == "" {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"type", hostConfig.Type,
+			"paramter", "token",
+		)
+	}
+
+	b := &incidentBackend{
+		service:  service,
+		token:    hostConfig.Token,
+		severity: hostConfig.Severity,
+		open:     make(map[string]bool),
+	}
+	if b.severity == "" {
+		b.severity = defaultIncidentSeverities[service]
+	}
+
+	return b
+}
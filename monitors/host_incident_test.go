@@ -0,0 +1,205 @@
+package monitors
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/coronon/uptime-robot/config"
+)
+
+// Request received by a fake incident service
+type incidentRequest struct {
+	path          string
+	query         string
+	authorization string
+	body          map[string]any
+}
+
+// Fake incident service recording all requests, failing the next `failures`
+type fakeIncidentService struct {
+	mu       sync.Mutex
+	requests []incidentRequest
+	failures int
+}
+
+func (s *fakeIncidentService) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	data, _ := io.ReadAll(req.Body)
+	var body map[string]any
+	json.Unmarshal(data, &body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.requests = append(s.requests, incidentRequest{
+		path:          req.URL.EscapedPath(),
+		query:         req.URL.RawQuery,
+		authorization: req.Header.Get("Authorization"),
+		body:          body,
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Requests received since the last call
+func (s *fakeIncidentService) take() []incidentRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
+
+func setupIncidentTest(t *testing.T, service string) (*host, *fakeIncidentService) {
+	t.Helper()
+
+	fake := &fakeIncidentService{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	h := setupHost(&config.Host{
+		Name:  service,
+		Type:  service,
+		URL:   srv.URL,
+		Token: "t0ken",
+	}, "node")
+
+	return h, fake
+}
+
+func incidentResult(status monitorStatus) *Result {
+	return &Result{
+		NodeName: "node",
+		Monitor:  "Disk /",
+		Type:     "disk_usage",
+		Status:   status,
+		Message:  "Exceeds threshold",
+	}
+}
+
+func TestPagerDutyTriggersAndResolves(t *testing.T) {
+	h, fake := setupIncidentTest(t, "pagerduty")
+
+	if err := h.backend.push(h, incidentResult(StatusDown)); err != nil {
+		t.Fatalf("trigger failed: %v", err)
+	}
+	requests := fake.take()
+	if len(requests) != 1 {
+		t.Fatalf("got %v requests, want 1", len(requests))
+	}
+	trigger := requests[0]
+	if trigger.path != "/v2/enqueue" {
+		t.Errorf("path = %q, want /v2/enqueue", trigger.path)
+	}
+	if trigger.body["event_action"] != "trigger" || trigger.body["routing_key"] != "t0ken" ||
+		trigger.body["dedup_key"] != "uptime-robot/node/Disk /" {
+		t.Errorf("unexpected trigger %v", trigger.body)
+	}
+	payload, _ := trigger.body["payload"].(map[string]any)
+	if payload["severity"] != "critical" || payload["source"] != "node" {
+		t.Errorf("unexpected payload %v", payload)
+	}
+
+	// Still down, the incident is already open
+	if err := h.backend.push(h, incidentResult(StatusDown)); err != nil {
+		t.Fatalf("repeated trigger failed: %v", err)
+	}
+	if requests := fake.take(); len(requests) != 0 {
+		t.Errorf("got %v requests while still down, want none", len(requests))
+	}
+
+	if err := h.backend.push(h, incidentResult(StatusUp)); err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	requests = fake.take()
+	if len(requests) != 1 || requests[0].body["event_action"] != "resolve" ||
+		requests[0].body["dedup_key"] != "uptime-robot/node/Disk /" {
+		t.Errorf("unexpected resolve %+v", requests)
+	}
+	if _, ok := requests[0].body["payload"]; ok {
+		t.Errorf("resolve should not contain a payload")
+	}
+}
+
+func TestPagerDutyRetriesFailedTrigger(t *testing.T) {
+	h, fake := setupIncidentTest(t, "pagerduty")
+	fake.failures = 1
+
+	if err := h.backend.push(h, incidentResult(StatusDown)); err == nil {
+		t.Fatal("expected the first trigger to fail")
+	}
+	if err := h.backend.push(h, incidentResult(StatusDown)); err != nil {
+		t.Fatalf("retried trigger failed: %v", err)
+	}
+
+	requests := fake.take()
+	if len(requests) != 1 || requests[0].body["event_action"] != "trigger" {
+		t.Errorf("expected the trigger to be retried, got %+v", requests)
+	}
+}
+
+func TestOpsgenieCreatesAndClosesAlert(t *testing.T) {
+	h, fake := setupIncidentTest(t, "opsgenie")
+
+	if err := h.backend.push(h, incidentResult(StatusDown)); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	requests := fake.take()
+	if len(requests) != 1 {
+		t.Fatalf("got %v requests, want 1", len(requests))
+	}
+	create := requests[0]
+	if create.path != "/v2/alerts" || create.authorization != "GenieKey t0ken" {
+		t.Errorf("unexpected request %+v", create)
+	}
+	if create.body["alias"] != "uptime-robot/node/Disk /" || create.body["priority"] != "P1" {
+		t.Errorf("unexpected alert %v", create.body)
+	}
+
+	if err := h.backend.push(h, incidentResult(StatusDown)); err != nil {
+		t.Fatalf("repeated create failed: %v", err)
+	}
+	if requests := fake.take(); len(requests) != 0 {
+		t.Errorf("got %v requests while still down, want none", len(requests))
+	}
+
+	if err := h.backend.push(h, incidentResult(StatusUp)); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	requests = fake.take()
+	if len(requests) != 1 {
+		t.Fatalf("got %v requests, want 1", len(requests))
+	}
+	closing := requests[0]
+	if closing.path != "/v2/alerts/uptime-robot%2Fnode%2FDisk%20%2F/close" {
+		t.Errorf("path = %q, want the escaped alias", closing.path)
+	}
+	if closing.query != "identifierType=alias" || closing.authorization != "GenieKey t0ken" {
+		t.Errorf("unexpected close %+v", closing)
+	}
+}
+
+func TestOpsgenieRetriesFailedCreate(t *testing.T) {
+	h, fake := setupIncidentTest(t, "opsgenie")
+	fake.failures = 1
+
+	if err := h.backend.push(h, incidentResult(StatusDown)); err == nil {
+		t.Fatal("expected the first create to fail")
+	}
+	if err := h.backend.push(h, incidentResult(StatusDown)); err != nil {
+		t.Fatalf("retried create failed: %v", err)
+	}
+
+	requests := fake.take()
+	if len(requests) != 1 || requests[0].path != "/v2/alerts" {
+		t.Errorf("expected the create to be retried, got %+v", requests)
+	}
+}
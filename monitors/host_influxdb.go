@@ -0,0 +1,158 @@
+package monitors
+
+import (
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Name of the measurement all results are written to
+const influxMeasurement = "uptime_robot"
+
+// Writes results in batches to InfluxDB using the v2 HTTP write API
+//
+// Every result is written as a single point in line protocol, tagged with
+// `node` (`node_name`), `monitor` and `type`.
+type influxBackend struct {
+	token  string
+	org    string
+	bucket string
+
+	batcher *batcher[string]
+}
+
+func (b *influxBackend) push(h *host, r *Result) error {
+	b.batcher.add(influxLine(r))
+	return nil
+}
+
+// Write a batch of lines to host `h`
+func (b *influxBackend) write(h *host, lines []string) error {
+	return h.tryURLs(func(hostURL string) error {
+		// Parse base URL
+		writeUrl, err := url.Parse(hostURL)
+		if err != nil {
+			return err
+		}
+
+		//? We already ensure that host ends with a trailing / in setupHost
+		writeUrl.Path += "api/v2/write"
+
+		params := url.Values{}
+		params.Add("org", b.org)
+		params.Add("bucket", b.bucket)
+		params.Add("precision", "ns")
+		writeUrl.RawQuery = params.Encode()
+
+		req, err := http.NewRequest(
+			http.MethodPost,
+			writeUrl.String(),
+			strings.NewReader(strings.Join(lines, "\n")),
+		)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Token "+b.token)
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+		zap.S().Debugw("Writing to InfluxDB",
+			"host", hostURL,
+			"lines", len(lines),
+			"url", req.URL.String(),
+		)
+
+		_, err = doPushRequest(req, h.timeout, &h.stats)
+		return err
+	})
+}
+
+// Format a result as a single point in line protocol
+func influxLine(r *Result) string {
+	var line strings.Builder
+
+	line.WriteString(influxMeasurement)
+	for _, tag := range [][2]string{{"monitor", r.Monitor}, {"node", r.NodeName}, {"type", r.Type}} {
+		// Tags with empty values are not allowed
+		if tag[1] != "" {
+			line.WriteString("," + tag[0] + "=" + escapeInfluxTag(tag[1]))
+		}
+	}
+
+	line.WriteString(" up=" + strconv.FormatFloat(statusValue(r.Status), 'f', -1, 64))
+	line.WriteString(",status=" + quoteInfluxString(string(r.Status)))
+	line.WriteString(",message=" + quoteInfluxString(r.Message))
+	line.WriteString(",ping=" + strconv.Itoa(r.Ping) + "i")
+	line.WriteString(",duration_seconds=" + strconv.FormatFloat(r.Duration.Seconds(), 'f', -1, 64))
+
+	names := make([]string, 0, len(r.Values))
+	for name := range r.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := r.Values[name]
+		//? Line protocol has no representation of NaN or infinity
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		line.WriteString("," + escapeInfluxTag(name) + "=" + strconv.FormatFloat(value, 'f', -1, 64))
+	}
+
+	line.WriteString(" " + strconv.FormatInt(r.Time.UnixNano(), 10))
+
+	return line.String()
+}
+
+// Escape a tag key, tag value or field key
+func escapeInfluxTag(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`).Replace(value)
+}
+
+// Quote a string field value
+func quoteInfluxString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// Setup the backend of a host of type 'influxdb'
+func setupInfluxBackend(h *host, hostConfig *config.Host) *influxBackend {
+	if hostConfig.Token == "" {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"type", hostConfig.Type,
+			"paramter", "token",
+		)
+	}
+
+	if hostConfig.Org == "" {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"type", hostConfig.Type,
+			"paramter", "org",
+		)
+	}
+
+	if hostConfig.Bucket == "" {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"type", hostConfig.Type,
+			"paramter", "bucket",
+		)
+	}
+
+	b := &influxBackend{
+		token:  hostConfig.Token,
+		org:    hostConfig.Org,
+		bucket: hostConfig.Bucket,
+	}
+	b.batcher = startBatcher(h.name, hostConfig.FlushInterval, hostConfig.BatchSize, func(lines []string) error {
+		return b.write(h, lines)
+	})
+
+	return b
+}
@@ -0,0 +1,374 @@
+package monitors
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Default size in megabytes after which a journal is rotated
+const defaultJournalMaxSize = 10
+
+// Default number of rotated journals that are kept
+const defaultJournalMaxBackups = 10
+
+// Columns of journals in the CSV format
+var journalCSVHeader = []string{
+	"time", "node", "monitor", "type", "key", "status", "message", "ping", "duration_seconds", "values", "run_id",
+}
+
+// Writes every result to a local file, either as JSON Lines or CSV
+//
+// The file is rotated once it exceeds `max_size` megabytes or is older than
+// `max_age` hours. Rotated files are renamed to include the time of rotation,
+// optionally compressed using gzip, and deleted once there are more than
+// `max_backups` of them.
+type journalBackend struct {
+	path   string
+	format string
+
+	// Size in bytes after which the file is rotated
+	maxSize int64
+	// Age after which the file is rotated, 0 to disable
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+
+	mu   sync.Mutex
+	file *os.File
+	// Current size of the file in bytes
+	size int64
+	// Time the file was created, taken from its first record if it already
+	// existed
+	opened time.Time
+
+	// Serializes compressing and removing rotated files in the background
+	cleanupMu sync.Mutex
+}
+
+func (b *journalBackend) push(h *host, r *Result) error {
+	line, err := b.encode(r)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := time.Now()
+	err = b.write(line)
+	h.stats.record(time.Since(start), err)
+
+	return err
+}
+
+// Encode a result as a single line in the format of the journal
+func (b *journalBackend) encode(r *Result) ([]byte, error) {
+	if b.format == "jsonl" {
+		line, err := json.Marshal(newResultPayload(r))
+		return append(line, '\n'), err
+	}
+
+	names := make([]string, 0, len(r.Values))
+	for name := range r.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = name + "=" + strconv.FormatFloat(r.Values[name], 'f', -1, 64)
+	}
+
+	return encodeCSV([]string{
+		r.Time.Format(time.RFC3339Nano),
+		r.NodeName,
+		r.Monitor,
+		r.Type,
+		r.Key,
+		string(r.Status),
+		r.Message,
+		strconv.Itoa(r.Ping),
+		strconv.FormatFloat(r.Duration.Seconds(), 'f', -1, 64),
+		strings.Join(values, ";"),
+		r.RunID,
+	})
+}
+
+// Append a line to the journal, opening or rotating the file if necessary
+//
+// Must be called with b.mu held
+func (b *journalBackend) write(line []byte) error {
+	//? Open before checking, so a journal that already got too old before a
+	//? restart is rotated right away
+	if b.file == nil {
+		if err := b.open(); err != nil {
+			return err
+		}
+	}
+
+	if b.size > 0 && (b.size+int64(len(line)) > b.maxSize || (b.maxAge > 0 && time.Since(b.opened) > b.maxAge)) {
+		if err := b.rotate(); err != nil {
+			return err
+		}
+		if err := b.open(); err != nil {
+			return err
+		}
+	}
+
+	n, err := b.file.Write(line)
+	b.size += int64(n)
+
+	return err
+}
+
+// Open the journal, writing the CSV header to new files
+//
+// Must be called with b.mu held
+func (b *journalBackend) open() error {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(b.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	b.file = file
+	b.size = info.Size()
+	b.opened = time.Now()
+	//? Keep the age of journals written before a restart
+	if b.size > 0 {
+		if first, ok := b.firstRecordTime(); ok {
+			b.opened = first
+		}
+	}
+
+	if b.format == "csv" && b.size == 0 {
+		header, err := encodeCSV(journalCSVHeader)
+		if err != nil {
+			return err
+		}
+		n, err := b.file.Write(header)
+		b.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Time of the first record in the journal, false if it has none or it could not
+// be read
+func (b *journalBackend) firstRecordTime() (time.Time, bool) {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer file.Close()
+
+	if b.format == "jsonl" {
+		line, err := bufio.NewReader(file).ReadBytes('\n')
+		if err != nil && len(line) == 0 {
+			return time.Time{}, false
+		}
+		var payload resultPayload
+		if json.Unmarshal(line, &payload) != nil || payload.Time.IsZero() {
+			return time.Time{}, false
+		}
+		return payload.Time, true
+	}
+
+	// Skip the header
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	if _, err := r.Read(); err != nil {
+		return time.Time{}, false
+	}
+	record, err := r.Read()
+	if err != nil || len(record) == 0 {
+		return time.Time{}, false
+	}
+	first, err := time.Parse(time.RFC3339Nano, record[0])
+	return first, err == nil
+}
+
+// Rotate the journal, compressing the rotated file and removing old ones in the
+// background
+//
+// Must be called with b.mu held
+func (b *journalBackend) rotate() error {
+	if err := b.file.Close(); err != nil {
+		return err
+	}
+	b.file = nil
+
+	ext := filepath.Ext(b.path)
+	rotated := strings.TrimSuffix(b.path, ext) + "-" + time.Now().Format("20060102T150405.000") + ext
+	if err := os.Rename(b.path, rotated); err != nil {
+		return err
+	}
+
+	zap.S().Infow("Rotated journal",
+		"path", b.path,
+		"rotated", rotated,
+	)
+
+	//? The file was already rotated, so failing to compress or clean up must
+	//? not fail the write
+	go b.cleanup(rotated)
+
+	return nil
+}
+
+// Compress the rotated journal `rotated` if enabled and remove old ones
+func (b *journalBackend) cleanup(rotated string) {
+	b.cleanupMu.Lock()
+	defer b.cleanupMu.Unlock()
+
+	if b.compress {
+		if err := compressFile(rotated); err != nil {
+			zap.S().Warnw("Could not compress rotated journal",
+				"path", rotated,
+				"error", err,
+			)
+		}
+	}
+	b.removeBackups()
+}
+
+// Remove the oldest rotated journals exceeding b.maxBackups
+func (b *journalBackend) removeBackups() {
+	dir, name := filepath.Split(b.path)
+	ext := filepath.Ext(name)
+	//? Only match files named like in rotate, so other files sharing the prefix
+	//? of the journal are never removed
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(strings.TrimSuffix(name, ext)) +
+		`-\d{8}T\d{6}\.\d{3}` + regexp.QuoteMeta(ext) + `(\.gz)?$`)
+
+	entries, err := os.ReadDir(filepath.Join(dir, "."))
+	if err != nil {
+		return
+	}
+	var backups []string
+	for _, entry := range entries {
+		if !entry.IsDir() && pattern.MatchString(entry.Name()) {
+			backups = append(backups, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(backups) <= b.maxBackups {
+		return
+	}
+
+	//? The time of rotation in their name sorts backups from old to new
+	sort.Strings(backups)
+	for _, backup := range backups[:len(backups)-b.maxBackups] {
+		if err := os.Remove(backup); err != nil {
+			zap.S().Warnw("Could not remove rotated journal",
+				"path", backup,
+				"error", err,
+			)
+		}
+	}
+}
+
+// Encode a single CSV record including the trailing newline
+func encodeCSV(record []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+// Compress `path` using gzip, replacing it with `path`.gz
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
+
+// Setup the backend of a host of type 'journal'
+func setupJournalBackend(hostConfig *config.Host) *journalBackend {
+	if hostConfig.FilePath == "" {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"type", hostConfig.Type,
+			"paramter", "file_path",
+		)
+	}
+
+	b := &journalBackend{
+		path:       hostConfig.FilePath,
+		format:     hostConfig.Format,
+		maxSize:    int64(hostConfig.MaxSize) << 20,
+		maxAge:     time.Duration(hostConfig.MaxAge) * time.Hour,
+		maxBackups: hostConfig.MaxBackups,
+		compress:   hostConfig.Compress,
+	}
+	if b.format == "" {
+		b.format = "jsonl"
+		if strings.EqualFold(filepath.Ext(b.path), ".csv") {
+			b.format = "csv"
+		}
+	}
+	if b.format != "jsonl" && b.format != "csv" {
+		zap.S().Panicw("Unknown journal format",
+			"name", hostConfig.Name,
+			"format", b.format,
+		)
+	}
+	if hostConfig.MaxSize == 0 {
+		b.maxSize = defaultJournalMaxSize << 20
+	}
+	if hostConfig.MaxBackups == 0 {
+		b.maxBackups = defaultJournalMaxBackups
+	}
+
+	return b
+}
@@ -0,0 +1,94 @@
+package monitors
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+)
+
+func setupJournalTest(t *testing.T, path string) (*host, *journalBackend) {
+	t.Helper()
+
+	h := setupHost(&config.Host{
+		Name:     "journal",
+		Type:     "journal",
+		FilePath: path,
+		MaxAge:   1,
+	}, "node")
+
+	return h, h.backend.(*journalBackend)
+}
+
+func TestJournalRotatesReopenedJournal(t *testing.T) {
+	for _, name := range []string{"results.jsonl", "results.csv"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, name)
+
+			h, b := setupJournalTest(t, path)
+			if err := b.push(h, &Result{Monitor: "Old", Status: StatusUp, Time: time.Now().Add(-2 * time.Hour)}); err != nil {
+				t.Fatalf("push failed: %v", err)
+			}
+			// Written recently, but the first record is older than max_age
+			b.file.Close()
+			now := time.Now()
+			os.Chtimes(path, now, now)
+
+			// Reopened after a restart
+			h, b = setupJournalTest(t, path)
+			if err := b.push(h, &Result{Monitor: "New", Status: StatusUp, Time: now}); err != nil {
+				t.Fatalf("push failed: %v", err)
+			}
+			b.file.Close()
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), "Old") || !strings.Contains(string(data), "New") {
+				t.Errorf("journal was not rotated, contains %q", data)
+			}
+
+			// Wait for the cleanup in the background
+			b.cleanupMu.Lock()
+			defer b.cleanupMu.Unlock()
+			backups, _ := filepath.Glob(filepath.Join(dir, "results-*"))
+			if len(backups) != 1 {
+				t.Fatalf("got backups %v, want one", backups)
+			}
+			data, _ = os.ReadFile(backups[0])
+			if !strings.Contains(string(data), "Old") {
+				t.Errorf("backup does not contain the old record: %q", data)
+			}
+		})
+	}
+}
+
+func TestJournalKeepsYoungReopenedJournal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results.jsonl")
+
+	h, b := setupJournalTest(t, path)
+	if err := b.push(h, &Result{Monitor: "Old", Status: StatusUp, Time: time.Now().Add(-30 * time.Minute)}); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	b.file.Close()
+
+	h, b = setupJournalTest(t, path)
+	if err := b.push(h, &Result{Monitor: "New", Status: StatusUp, Time: time.Now()}); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	b.file.Close()
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "Old") || !strings.Contains(string(data), "New") {
+		t.Errorf("journal should still contain both records: %q", data)
+	}
+	if backups, _ := filepath.Glob(filepath.Join(dir, "results-*")); len(backups) != 0 {
+		t.Errorf("got backups %v, want none", backups)
+	}
+}
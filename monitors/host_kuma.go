@@ -0,0 +1,355 @@
+package monitors
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Time provisioning monitors on Uptime-Kuma may take
+const kumaProvisionTimeout = 60 * time.Second
+
+// Time between two attempts to provision monitors on Uptime-Kuma
+const kumaProvisionRetry = 60 * time.Second
+
+// Uptime-Kuma's minimum interval of monitors in seconds
+const kumaMinInterval = 20
+
+// Pushes results using Uptime-Kuma's push format or the request templates of
+// the host
+type kumaBackend struct {
+	// Templates used to build push requests
+	request *requestTemplate
+	// nil unless monitors without a key are provisioned
+	provisioner *kumaProvisioner
+}
+
+// Creates or updates push monitors on Uptime-Kuma using its Socket.IO API
+//
+// Monitors are named after the node and the robot's monitor. Provisioning runs
+// in the background and is retried until it succeeds. Push tokens are stored
+// in a file, so pushes continue with the known tokens in the meantime.
+type kumaProvisioner struct {
+	// Base URL of Uptime-Kuma ending with a trailing '/'
+	endpoint  string
+	username  string
+	password  string
+	nodeName  string
+	tokenFile string
+
+	mu sync.Mutex
+	// Push tokens by name of the robot's monitor
+	tokens map[string]string
+}
+
+// Acknowledgement of Uptime-Kuma's API calls
+type kumaResponse struct {
+	OK        bool   `json:"ok"`
+	Msg       string `json:"msg"`
+	MonitorID int    `json:"monitorID"`
+}
+
+func (b *kumaBackend) push(h *host, r *Result) error {
+	if r.Key == "" && b.provisioner != nil {
+		r.Key = b.provisioner.token(r.Monitor)
+		if r.Key == "" {
+			return errors.New("monitor was not provisioned yet")
+		}
+	}
+
+	return h.tryURLs(func(hostURL string) error {
+		return b.pushToURL(h, hostURL, r)
+	})
+}
+
+// Push a single result to one URL of host `h`
+func (b *kumaBackend) pushToURL(h *host, hostURL string, r *Result) error {
+	req, err := b.request.build(hostURL, r)
+	if err != nil {
+		zap.S().DPanicw("Could not build push request",
+			"url", hostURL,
+			"error", err.Error(),
+		)
+		return err
+	}
+
+	zap.S().Debugw("Pushing to host",
+		"host", hostURL,
+		"key", r.Key,
+		"status", r.Status,
+		"message", r.Message,
+		"pingMs", r.Ping,
+		"method", req.Method,
+		"url", req.URL.String(),
+	)
+
+	_, err = doPushRequest(req, h.timeout, &h.stats)
+	return err
+}
+
+func (b *kumaBackend) provision(h *host, monitors []provisionedMonitor) {
+	if b.provisioner == nil {
+		return
+	}
+
+	//? An unreachable Uptime-Kuma must not delay startup, pushes use the stored
+	//? tokens until provisioning succeeds
+	go func() {
+		for {
+			err := b.provisioner.provision(monitors)
+			if err == nil {
+				return
+			}
+
+			zap.S().Warnw("Could not provision monitors, retrying in background",
+				"host", h.name,
+				"error", err,
+			)
+			time.Sleep(kumaProvisionRetry)
+		}
+	}()
+}
+
+// Push token of the robot's monitor `name`, empty if unknown
+func (p *kumaProvisioner) token(name string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.tokens[name]
+}
+
+// Create or update `monitors` on Uptime-Kuma, storing their push tokens
+func (p *kumaProvisioner) provision(monitors []provisionedMonitor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kumaProvisionTimeout)
+	defer cancel()
+
+	c, err := dialSocketIO(ctx, p.endpoint+"socket.io/")
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	var resp kumaResponse
+	err = c.emit(&resp, "login", map[string]string{
+		"username": p.username,
+		"password": p.password,
+		"token":    "",
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.OK {
+		return fmt.Errorf("login failed: %v", resp.Msg)
+	}
+
+	//? Uptime-Kuma sends all monitors of the user after logging in
+	var existing map[string]map[string]any
+	if err := c.await(&existing, "monitorList"); err != nil {
+		return err
+	}
+	byName := make(map[string]map[string]any, len(existing))
+	for _, monitor := range existing {
+		if monitor["type"] == "push" {
+			name, _ := monitor["name"].(string)
+			byName[name] = monitor
+		}
+	}
+
+	for _, m := range monitors {
+		name := p.nodeName + " / " + m.name
+		//? Leave room for runs that take longer and pushes that are retried
+		interval := int((m.maxGap + m.maxGap/2 + time.Second - 1) / time.Second)
+		if interval < kumaMinInterval {
+			interval = kumaMinInterval
+		}
+
+		monitor, ok := byName[name]
+		event := "editMonitor"
+		if !ok {
+			event = "add"
+			monitor = map[string]any{
+				"type":                     "push",
+				"name":                     name,
+				"description":              "Provisioned by Uptime-Robot",
+				"resendInterval":           0,
+				"accepted_statuscodes":     []string{"200-299"},
+				"notificationIDList":       map[string]bool{},
+				"kafkaProducerBrokers":     []string{},
+				"kafkaProducerSaslOptions": map[string]string{"mechanism": "None"},
+			}
+		}
+
+		token, _ := monitor["pushToken"].(string)
+		if token == "" {
+			token = p.token(m.name)
+		}
+		if token == "" {
+			if token, err = generatePushToken(); err != nil {
+				return err
+			}
+		}
+
+		if ok && monitor["pushToken"] == token && kumaInt(monitor["interval"]) == interval &&
+			kumaInt(monitor["retryInterval"]) == interval && kumaInt(monitor["maxretries"]) == 1 {
+			zap.S().Debugw("Monitor already provisioned",
+				"monitor", m.name,
+				"name", name,
+			)
+		} else {
+			monitor["pushToken"] = token
+			monitor["interval"] = interval
+			monitor["retryInterval"] = interval
+			monitor["maxretries"] = 1
+
+			if err := c.emit(&resp, event, monitor); err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("could not provision monitor %v: %v", name, resp.Msg)
+			}
+
+			zap.S().Infow("Provisioned monitor",
+				"monitor", m.name,
+				"name", name,
+				"created", !ok,
+				"interval", interval,
+			)
+		}
+
+		p.mu.Lock()
+		p.tokens[m.name] = token
+		p.mu.Unlock()
+		if err := p.saveTokens(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Load stored push tokens, keeping none if the file does not exist yet
+func (p *kumaProvisioner) loadTokens() error {
+	data, err := os.ReadFile(p.tokenFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &p.tokens)
+}
+
+// Store all known push tokens, replacing the file atomically
+func (p *kumaProvisioner) saveTokens() error {
+	p.mu.Lock()
+	data, err := json.MarshalIndent(p.tokens, "", "  ")
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := p.tokenFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, p.tokenFile)
+}
+
+// Generate a random push token like Uptime-Kuma does
+func generatePushToken() (string, error) {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+	token := make([]byte, 32)
+	for i := range token {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		token[i] = chars[n.Int64()]
+	}
+
+	return string(token), nil
+}
+
+// Integer value of a number decoded from Uptime-Kuma's JSON
+func kumaInt(v any) int {
+	f, _ := v.(float64)
+	return int(f)
+}
+
+// Setup the backend of a host of type 'kuma'
+func setupKumaBackend(hostConfig *config.Host, nodeName string) *kumaBackend {
+	b := &kumaBackend{request: setupRequestTemplate(hostConfig)}
+	if !hostConfig.Provision {
+		return b
+	}
+
+	if hostConfig.Username == "" {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"type", hostConfig.Type,
+			"paramter", "username",
+		)
+	}
+	if hostConfig.Password == "" {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"type", hostConfig.Type,
+			"paramter", "password",
+		)
+	}
+
+	b.provisioner = &kumaProvisioner{
+		endpoint:  hostConfig.ProvisionURL,
+		username:  hostConfig.Username,
+		password:  hostConfig.Password,
+		nodeName:  nodeName,
+		tokenFile: hostConfig.TokenFile,
+		tokens:    make(map[string]string),
+	}
+
+	// Derive Uptime-Kuma's base URL from its push URL
+	if b.provisioner.endpoint == "" {
+		pushURL := hostConfig.URL
+		if pushURL == "" {
+			pushURL = hostConfig.URLs[0]
+		}
+		pushURL = strings.TrimSuffix(pushURL, "/") + "/"
+		if !strings.HasSuffix(pushURL, "/api/push/") {
+			zap.S().Panicw("Missing paramter for host",
+				"name", hostConfig.Name,
+				"type", hostConfig.Type,
+				"paramter", "provision_url",
+			)
+		}
+		b.provisioner.endpoint = strings.TrimSuffix(pushURL, "api/push/")
+	}
+	if !strings.HasSuffix(b.provisioner.endpoint, "/") {
+		b.provisioner.endpoint += "/"
+	}
+	if b.provisioner.tokenFile == "" {
+		b.provisioner.tokenFile = hostConfig.Name + "-tokens.json"
+	}
+
+	if err := b.provisioner.loadTokens(); err != nil {
+		zap.S().Panicw("Could not load push tokens",
+			"name", hostConfig.Name,
+			"file", b.provisioner.tokenFile,
+			"error", err,
+		)
+	}
+
+	return b
+}
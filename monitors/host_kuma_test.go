@@ -0,0 +1,322 @@
+package monitors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Event emitted by the client of a fake Uptime-Kuma
+type kumaEvent struct {
+	name    string
+	monitor map[string]any
+}
+
+// Fake Uptime-Kuma speaking Engine.IO v4 using HTTP long-polling
+//
+// Supports logging in and adding or editing monitors, all other events are
+// acknowledged as failed.
+type fakeKuma struct {
+	password string
+
+	mu sync.Mutex
+	// Monitors by ID
+	monitors map[string]map[string]any
+	// Events received after logging in
+	events []kumaEvent
+	// Packets queued for the next poll by session ID
+	sessions map[string][]string
+	lastSID  int
+}
+
+func newFakeKuma(t *testing.T, password string, monitors ...map[string]any) (*fakeKuma, string) {
+	t.Helper()
+
+	fake := &fakeKuma{
+		password: password,
+		monitors: make(map[string]map[string]any),
+		sessions: make(map[string][]string),
+	}
+	for i, monitor := range monitors {
+		id := i + 1
+		monitor["id"] = id
+		fake.monitors[strconv.Itoa(id)] = monitor
+	}
+
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	return fake, srv.URL + "/"
+}
+
+func (f *fakeKuma) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if req.URL.Path != "/socket.io/" || query.Get("EIO") != "4" || query.Get("transport") != "polling" {
+		http.NotFound(w, req)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sid := query.Get("sid")
+	if sid == "" && req.Method == http.MethodGet {
+		f.lastSID++
+		sid = strconv.Itoa(f.lastSID)
+		// Ping right away to make sure the client answers pings
+		f.sessions[sid] = []string{"2"}
+		fmt.Fprintf(w, `0{"sid":%q,"upgrades":[],"pingInterval":25000,"pingTimeout":20000}`, sid)
+		return
+	}
+	if _, ok := f.sessions[sid]; !ok {
+		http.Error(w, "unknown session", http.StatusBadRequest)
+		return
+	}
+
+	if req.Method == http.MethodGet {
+		deadline := time.Now().Add(time.Second)
+		for len(f.sessions[sid]) == 0 && time.Now().Before(deadline) {
+			f.mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			f.mu.Lock()
+		}
+		packets := f.sessions[sid]
+		f.sessions[sid] = nil
+		if len(packets) == 0 {
+			// Noop, the client has to poll again
+			packets = []string{"6"}
+		}
+		io.WriteString(w, strings.Join(packets, engineIOSeparator))
+		return
+	}
+
+	data, _ := io.ReadAll(req.Body)
+	for _, packet := range strings.Split(string(data), engineIOSeparator) {
+		f.sessions[sid] = append(f.sessions[sid], f.handle(packet)...)
+	}
+	io.WriteString(w, "ok")
+}
+
+// Packets replying to `packet`
+//
+// Must be called with f.mu held
+func (f *fakeKuma) handle(packet string) []string {
+	switch {
+	case packet == "40":
+		return []string{`40{"sid":"socket"}`}
+	case !strings.HasPrefix(packet, "42"):
+		return nil
+	}
+
+	i := 2
+	for i < len(packet) && packet[i] >= '0' && packet[i] <= '9' {
+		i++
+	}
+	id := packet[2:i]
+	var args []json.RawMessage
+	var event string
+	if json.Unmarshal([]byte(packet[i:]), &args) != nil || len(args) < 2 || json.Unmarshal(args[0], &event) != nil {
+		return nil
+	}
+	var arg map[string]any
+	json.Unmarshal(args[1], &arg)
+
+	ack := func(resp map[string]any) string {
+		data, _ := json.Marshal([]any{resp})
+		return "43" + id + string(data)
+	}
+
+	if event == "login" {
+		if arg["password"] != f.password {
+			return []string{ack(map[string]any{"ok": false, "msg": "Incorrect username or password."})}
+		}
+		list, _ := json.Marshal([]any{"monitorList", f.monitors})
+		return []string{ack(map[string]any{"ok": true, "token": "jwt"}), "42" + string(list)}
+	}
+
+	f.events = append(f.events, kumaEvent{name: event, monitor: arg})
+	switch event {
+	case "add":
+		id := len(f.monitors) + 1
+		arg["id"] = id
+		f.monitors[strconv.Itoa(id)] = arg
+		return []string{ack(map[string]any{"ok": true, "msg": "Added Successfully.", "monitorID": id})}
+	case "editMonitor":
+		id := strconv.Itoa(kumaInt(arg["id"]))
+		if _, ok := f.monitors[id]; !ok {
+			return []string{ack(map[string]any{"ok": false, "msg": "Monitor not found"})}
+		}
+		f.monitors[id] = arg
+		return []string{ack(map[string]any{"ok": true, "msg": "Saved.", "monitorID": kumaInt(arg["id"])})}
+	}
+	return []string{ack(map[string]any{"ok": false, "msg": "Unknown event"})}
+}
+
+// Events received since the last call
+func (f *fakeKuma) take() []kumaEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events := f.events
+	f.events = nil
+	return events
+}
+
+func newTestProvisioner(t *testing.T, endpoint string, tokenFile string) *kumaProvisioner {
+	t.Helper()
+
+	p := &kumaProvisioner{
+		endpoint:  endpoint,
+		username:  "admin",
+		password:  "secret",
+		nodeName:  "node",
+		tokenFile: tokenFile,
+		tokens:    make(map[string]string),
+	}
+	if err := p.loadTokens(); err != nil {
+		t.Fatalf("could not load tokens: %v", err)
+	}
+
+	return p
+}
+
+func TestKumaProvisionLoginFailure(t *testing.T) {
+	fake, endpoint := newFakeKuma(t, "other")
+	p := newTestProvisioner(t, endpoint, filepath.Join(t.TempDir(), "tokens.json"))
+
+	err := p.provision([]provisionedMonitor{{name: "Disk", maxGap: time.Minute}})
+	if err == nil || !strings.Contains(err.Error(), "Incorrect username or password.") {
+		t.Fatalf("err = %v, want the login failure", err)
+	}
+	if events := fake.take(); len(events) != 0 {
+		t.Errorf("got %+v after a failed login, want no events", events)
+	}
+	if token := p.token("Disk"); token != "" {
+		t.Errorf("token = %q, want none", token)
+	}
+}
+
+func TestKumaProvisionCreatesMonitor(t *testing.T) {
+	fake, endpoint := newFakeKuma(t, "secret", map[string]any{
+		"type": "http",
+		"name": "node / Disk",
+	})
+	p := newTestProvisioner(t, endpoint, filepath.Join(t.TempDir(), "tokens.json"))
+
+	if err := p.provision([]provisionedMonitor{{name: "Disk", maxGap: time.Minute}}); err != nil {
+		t.Fatalf("provision failed: %v", err)
+	}
+
+	// The HTTP monitor of the same name is not a push monitor
+	events := fake.take()
+	if len(events) != 1 || events[0].name != "add" {
+		t.Fatalf("got %+v, want a single add", events)
+	}
+	monitor := events[0].monitor
+	if monitor["type"] != "push" || monitor["name"] != "node / Disk" {
+		t.Errorf("unexpected monitor %v", monitor)
+	}
+	if kumaInt(monitor["interval"]) != 90 || kumaInt(monitor["retryInterval"]) != 90 || kumaInt(monitor["maxretries"]) != 1 {
+		t.Errorf("unexpected intervals of %v", monitor)
+	}
+	token, _ := monitor["pushToken"].(string)
+	if len(token) != 32 {
+		t.Errorf("pushToken = %q, want 32 characters", token)
+	}
+	if p.token("Disk") != token {
+		t.Errorf("token = %q, want %q", p.token("Disk"), token)
+	}
+}
+
+func TestKumaProvisionEditsMonitor(t *testing.T) {
+	fake, endpoint := newFakeKuma(t, "secret", map[string]any{
+		"type":          "push",
+		"name":          "node / Disk",
+		"description":   "Keep me",
+		"pushToken":     "EXISTING",
+		"interval":      60,
+		"retryInterval": 60,
+		"maxretries":    0,
+	})
+	p := newTestProvisioner(t, endpoint, filepath.Join(t.TempDir(), "tokens.json"))
+
+	// Below Uptime-Kuma's minimum interval
+	if err := p.provision([]provisionedMonitor{{name: "Disk", maxGap: 5 * time.Second}}); err != nil {
+		t.Fatalf("provision failed: %v", err)
+	}
+
+	events := fake.take()
+	if len(events) != 1 || events[0].name != "editMonitor" {
+		t.Fatalf("got %+v, want a single editMonitor", events)
+	}
+	monitor := events[0].monitor
+	if kumaInt(monitor["id"]) != 1 || monitor["description"] != "Keep me" || monitor["pushToken"] != "EXISTING" {
+		t.Errorf("existing fields were not kept in %v", monitor)
+	}
+	if kumaInt(monitor["interval"]) != kumaMinInterval || kumaInt(monitor["retryInterval"]) != kumaMinInterval ||
+		kumaInt(monitor["maxretries"]) != 1 {
+		t.Errorf("unexpected intervals of %v", monitor)
+	}
+	if p.token("Disk") != "EXISTING" {
+		t.Errorf("token = %q, want EXISTING", p.token("Disk"))
+	}
+}
+
+func TestKumaProvisionSkipsUpToDateMonitor(t *testing.T) {
+	fake, endpoint := newFakeKuma(t, "secret", map[string]any{
+		"type":          "push",
+		"name":          "node / Disk",
+		"pushToken":     "EXISTING",
+		"interval":      90,
+		"retryInterval": 90,
+		"maxretries":    1,
+	})
+	p := newTestProvisioner(t, endpoint, filepath.Join(t.TempDir(), "tokens.json"))
+
+	if err := p.provision([]provisionedMonitor{{name: "Disk", maxGap: time.Minute}}); err != nil {
+		t.Fatalf("provision failed: %v", err)
+	}
+
+	if events := fake.take(); len(events) != 0 {
+		t.Errorf("got %+v, want no events for an up to date monitor", events)
+	}
+	if p.token("Disk") != "EXISTING" {
+		t.Errorf("token = %q, want EXISTING", p.token("Disk"))
+	}
+}
+
+func TestKumaProvisionPersistsTokens(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "tokens.json")
+
+	_, endpoint := newFakeKuma(t, "secret")
+	p := newTestProvisioner(t, endpoint, tokenFile)
+	if err := p.provision([]provisionedMonitor{{name: "Disk", maxGap: time.Minute}}); err != nil {
+		t.Fatalf("provision failed: %v", err)
+	}
+	token := p.token("Disk")
+
+	// Tokens are available right after a restart, before provisioning
+	reloaded := newTestProvisioner(t, "", tokenFile)
+	if reloaded.token("Disk") != token {
+		t.Fatalf("reloaded token = %q, want %q", reloaded.token("Disk"), token)
+	}
+
+	// A monitor deleted in Uptime-Kuma is recreated with the stored token
+	fake, endpoint := newFakeKuma(t, "secret")
+	reloaded.endpoint = endpoint
+	if err := reloaded.provision([]provisionedMonitor{{name: "Disk", maxGap: time.Minute}}); err != nil {
+		t.Fatalf("provision failed: %v", err)
+	}
+	events := fake.take()
+	if len(events) != 1 || events[0].name != "add" || events[0].monitor["pushToken"] != token {
+		t.Errorf("got %+v, want the monitor to be added with token %q", events, token)
+	}
+}
@@ -0,0 +1,340 @@
+package monitors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Defaults of hosts of type 'mqtt'
+const (
+	defaultMQTTTopic           = "uptime-robot"
+	defaultMQTTDiscoveryPrefix = "homeassistant"
+	mqttKeepAlive              = 60 * time.Second
+	// Time between two attempts to connect to the broker
+	mqttReconnectDelay = 10 * time.Second
+)
+
+// Publishes results to an MQTT broker
+//
+// Every monitor has its own retained state topic
+// `{topic}/{node}/{monitor}/state`. The availability topic `{topic}/{node}/status`
+// is set to `online` when connecting and to `offline` by the broker once the
+// connection is lost (last will). Optionally, Home Assistant discovery messages
+// are published so every monitor shows up as a binary sensor plus one sensor
+// per value.
+//
+// The backend connects as soon as it is set up and reconnects in the background
+// whenever the connection is lost, so the node shows up as online before the
+// first result is published.
+type mqttBackend struct {
+	clientID string
+	username string
+	password string
+	// Prefix of all topics
+	topic string
+	// Whether to publish Home Assistant discovery messages
+	discovery       bool
+	discoveryPrefix string
+	// Used in topics, also identifies this node in Home Assistant
+	nodeID   string
+	nodeName string
+
+	mu     sync.Mutex
+	client *mqttClient
+	// Discovered monitors mapped to the values they were discovered with
+	discovered map[string]string
+}
+
+// Payload published to the state topic of a monitor
+type mqttState struct {
+	Status          monitorStatus      `json:"status"`
+	Message         string             `json:"message"`
+	Ping            int                `json:"ping"`
+	DurationSeconds float64            `json:"duration_seconds"`
+	Values          map[string]float64 `json:"values"`
+	Time            time.Time          `json:"time"`
+}
+
+func (b *mqttBackend) push(h *host, r *Result) error {
+	start := time.Now()
+	err := b.publishResult(h, r)
+	h.stats.record(time.Since(start), err)
+
+	return err
+}
+
+// Publish the state of a result, including its discovery messages if enabled
+func (b *mqttBackend) publishResult(h *host, r *Result) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	client, err := b.connect(h)
+	if err != nil {
+		return err
+	}
+
+	monitorID := mqttMonitorID(r)
+	if b.discovery {
+		if err := b.publishDiscovery(h, client, monitorID, r); err != nil {
+			return err
+		}
+	}
+
+	payload, err := json.Marshal(mqttState{
+		Status:          r.Status,
+		Message:         r.Message,
+		Ping:            r.Ping,
+		DurationSeconds: r.Duration.Seconds(),
+		Values:          r.Values,
+		Time:            r.Time,
+	})
+	if err != nil {
+		return err
+	}
+
+	zap.S().Debugw("Publishing to MQTT",
+		"host", h.name,
+		"topic", b.stateTopic(monitorID),
+		"status", r.Status,
+		"message", r.Message,
+	)
+
+	return client.publish(b.stateTopic(monitorID), payload, true, h.timeout)
+}
+
+// Get the current connection to the broker, connecting if necessary
+//
+// Must be called with b.mu held
+func (b *mqttBackend) connect(h *host) (*mqttClient, error) {
+	if b.client != nil && b.client.alive() {
+		return b.client, nil
+	}
+
+	opts := mqttConnectOptions{
+		clientID:    b.clientID,
+		username:    b.username,
+		password:    b.password,
+		keepAlive:   mqttKeepAlive,
+		willTopic:   b.availabilityTopic(),
+		willPayload: []byte("offline"),
+		willRetain:  true,
+	}
+
+	err := h.tryURLs(func(hostURL string) error {
+		brokerUrl, err := url.Parse(hostURL)
+		if err != nil {
+			return err
+		}
+
+		useTLS := brokerUrl.Scheme == "mqtts"
+		address := brokerUrl.Host
+		if brokerUrl.Port() == "" {
+			if useTLS {
+				address = net.JoinHostPort(brokerUrl.Hostname(), "8883")
+			} else {
+				address = net.JoinHostPort(brokerUrl.Hostname(), "1883")
+			}
+		}
+
+		zap.S().Debugw("Connecting to MQTT broker",
+			"host", h.name,
+			"address", address,
+			"tls", useTLS,
+		)
+
+		client, err := dialMQTT(address, useTLS, opts, h.timeout)
+		if err != nil {
+			return err
+		}
+
+		if err := client.publish(b.availabilityTopic(), []byte("online"), true, h.timeout); err != nil {
+			client.closeWithError(err)
+			return err
+		}
+
+		b.client = client
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	zap.S().Infow("Connected to MQTT broker",
+		"host", h.name,
+	)
+
+	// Publish discovery messages again in case the broker lost them
+	b.discovered = make(map[string]string)
+
+	return b.client, nil
+}
+
+// Keep a connection to the broker, reconnecting once it is lost
+//
+// Should be called in a go-routine
+func (b *mqttBackend) maintain(h *host) {
+	for {
+		b.mu.Lock()
+		client, err := b.connect(h)
+		b.mu.Unlock()
+		if err != nil {
+			zap.S().Warnw("Could not connect to MQTT broker",
+				"host", h.name,
+				"error", err,
+			)
+			time.Sleep(mqttReconnectDelay)
+			continue
+		}
+
+		<-client.done
+		zap.S().Warnw("Lost connection to MQTT broker",
+			"host", h.name,
+			"error", client.err,
+		)
+	}
+}
+
+// Publish Home Assistant discovery messages for a monitor unless they were
+// already published with the same values
+func (b *mqttBackend) publishDiscovery(h *host, client *mqttClient, monitorID string, r *Result) error {
+	names := make([]string, 0, len(r.Values))
+	for name := range r.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	signature := strings.Join(names, ",")
+	if discovered, ok := b.discovered[monitorID]; ok && discovered == signature {
+		return nil
+	}
+
+	device := map[string]any{
+		"identifiers":  []string{"uptime_robot_" + b.nodeID},
+		"name":         b.nodeName,
+		"manufacturer": "Uptime-Robot",
+	}
+	objectID := b.nodeID + "_" + monitorID
+
+	configs := map[string]map[string]any{
+		"binary_sensor/" + b.nodeID + "/" + monitorID: {
+			"name":                  r.Monitor,
+			"unique_id":             "uptime_robot_" + objectID,
+			"state_topic":           b.stateTopic(monitorID),
+			"value_template":        "{{ 'ON' if value_json.status == 'up' else 'OFF' }}",
+			"device_class":          "connectivity",
+			"json_attributes_topic": b.stateTopic(monitorID),
+			"availability_topic":    b.availabilityTopic(),
+			"device":                device,
+		},
+	}
+	for _, name := range names {
+		sensor := map[string]any{
+			"name":               r.Monitor + " " + strings.ReplaceAll(name, "_", " "),
+			"unique_id":          "uptime_robot_" + objectID + "_" + name,
+			"state_topic":        b.stateTopic(monitorID),
+			"value_template":     fmt.Sprintf("{{ value_json['values']['%s'] }}", name),
+			"state_class":        "measurement",
+			"availability_topic": b.availabilityTopic(),
+			"device":             device,
+		}
+		if strings.HasSuffix(name, "_percent") {
+			sensor["unit_of_measurement"] = "%"
+		} else if strings.HasSuffix(name, "_seconds") {
+			sensor["unit_of_measurement"] = "s"
+			sensor["device_class"] = "duration"
+		}
+
+		configs["sensor/"+b.nodeID+"/"+monitorID+"_"+mqttID(name)] = sensor
+	}
+
+	for component, config := range configs {
+		payload, err := json.Marshal(config)
+		if err != nil {
+			return err
+		}
+
+		topic := b.discoveryPrefix + "/" + component + "/config"
+		zap.S().Debugw("Publishing Home Assistant discovery",
+			"host", h.name,
+			"topic", topic,
+		)
+
+		if err := client.publish(topic, payload, true, h.timeout); err != nil {
+			return err
+		}
+	}
+
+	b.discovered[monitorID] = signature
+	return nil
+}
+
+// Topic signaling whether this node is online
+func (b *mqttBackend) availabilityTopic() string {
+	return b.topic + "/" + b.nodeID + "/status"
+}
+
+// Topic the state of a monitor is published to
+func (b *mqttBackend) stateTopic(monitorID string) string {
+	return b.topic + "/" + b.nodeID + "/" + monitorID + "/state"
+}
+
+// Identify a monitor in topics, using its key if defined
+func mqttMonitorID(r *Result) string {
+	if r.Key != "" {
+		return mqttID(r.Key)
+	}
+
+	return mqttID(r.Monitor)
+}
+
+// Convert a name to an ID only containing characters allowed in topics and
+// Home Assistant object IDs
+func mqttID(name string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(name) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '_' || c == '-' {
+			b.WriteRune(c)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+
+	return b.String()
+}
+
+// Setup the backend of a host of type 'mqtt'
+func setupMQTTBackend(h *host, hostConfig *config.Host, nodeName string) *mqttBackend {
+	b := &mqttBackend{
+		clientID:        hostConfig.ClientID,
+		username:        hostConfig.Username,
+		password:        hostConfig.Password,
+		topic:           strings.TrimSuffix(hostConfig.Topic, "/"),
+		discovery:       hostConfig.Discovery,
+		discoveryPrefix: strings.TrimSuffix(hostConfig.DiscoveryPrefix, "/"),
+		nodeID:          mqttID(nodeName),
+		nodeName:        nodeName,
+	}
+
+	if b.topic == "" {
+		b.topic = defaultMQTTTopic
+	}
+	if b.discoveryPrefix == "" {
+		b.discoveryPrefix = defaultMQTTDiscoveryPrefix
+	}
+	if b.clientID == "" {
+		b.clientID = "uptime-robot-" + b.nodeID
+	}
+
+	go b.maintain(h)
+
+	return b
+}
@@ -0,0 +1,106 @@
+package monitors
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Priorities of ntfy messages by status
+const (
+	ntfyPriorityUp   = 3
+	ntfyPriorityDown = 4
+)
+
+// Sends push notifications using ntfy (https://ntfy.sh) whenever a monitor
+// changes its status
+//
+// Messages are published as JSON to the root URL of the server. Down messages
+// have a high priority, tags and the click URL are taken from the monitor.
+type ntfyBackend struct {
+	topic string
+	// Access token, takes precedence over username and password
+	token    string
+	username string
+	password string
+}
+
+// Message as accepted by ntfy's JSON publishing API
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags,omitempty"`
+	Click    string   `json:"click,omitempty"`
+}
+
+func (b *ntfyBackend) push(h *host, r *Result) error {
+	n := newNotification(r)
+
+	message := ntfyMessage{
+		Topic:    b.topic,
+		Title:    n.title,
+		Message:  n.details(),
+		Priority: ntfyPriorityDown,
+		//? Tags matching an emoji short code are shown as emoji by ntfy
+		Tags:  append([]string{"rotating_light"}, r.Tags...),
+		Click: r.ClickURL,
+	}
+	if r.Status == StatusUp {
+		message.Priority = ntfyPriorityUp
+		message.Tags[0] = "white_check_mark"
+	}
+	if message.Message == "" {
+		message.Message = n.title
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	return h.tryURLs(func(hostURL string) error {
+		req, err := http.NewRequest(http.MethodPost, hostURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		if b.token != "" {
+			req.Header.Set("Authorization", "Bearer "+b.token)
+		} else if b.username != "" {
+			req.SetBasicAuth(b.username, b.password)
+		}
+
+		zap.S().Debugw("Sending ntfy notification",
+			"host", h.name,
+			"topic", b.topic,
+			"status", r.Status,
+			"priority", message.Priority,
+		)
+
+		_, err = doPushRequest(req, h.timeout, &h.stats)
+		return err
+	})
+}
+
+// Setup the backend of a host of type 'ntfy'
+func setupNtfyBackend(hostConfig *config.Host) *ntfyBackend {
+	if hostConfig.Topic == "" {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"paramter", "topic",
+		)
+	}
+
+	return &ntfyBackend{
+		topic:    hostConfig.Topic,
+		token:    hostConfig.Token,
+		username: hostConfig.Username,
+		password: hostConfig.Password,
+	}
+}
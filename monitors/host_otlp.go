@@ -0,0 +1,351 @@
+package monitors
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Identifiers used in OTLP exports
+const (
+	otlpScope         = "uptime-robot"
+	otlpMetricPrefix  = "uptime_robot."
+	otlpDeltaTemporal = 1
+)
+
+// Bucket boundaries of the duration histogram in seconds
+var otlpDurationBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Exports results to an OpenTelemetry collector using OTLP/HTTP with JSON
+// encoding
+//
+// Every result is exported as metrics: the gauge `uptime_robot.up`, the
+// histogram `uptime_robot.duration` and one gauge `uptime_robot.{value}` per
+// value of the monitor, all with the attributes `monitor` and `type`. Status
+// changes are additionally exported as log records.
+//
+// The resource is described by `host.name` (`node_name`), `service.name` and
+// the labels of the host. Metrics and logs are batched separately.
+type otlpBackend struct {
+	resource otlpResource
+	headers  map[string]string
+
+	metrics *batcher[Result]
+	logs    *batcher[Result]
+}
+
+// OTLP JSON encoding, see
+// https://github.com/open-telemetry/opentelemetry-proto/tree/main/opentelemetry/proto
+//
+// 64 bit integers are encoded as strings as required by the protobuf JSON
+// mapping.
+type (
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeInfo struct {
+		Name string `json:"name"`
+	}
+
+	otlpMetricsRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScopeInfo `json:"scope"`
+		Metrics []*otlpMetric `json:"metrics"`
+	}
+	otlpMetric struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Unit        string         `json:"unit,omitempty"`
+		Gauge       *otlpGauge     `json:"gauge,omitempty"`
+		Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+	}
+	otlpNumberDataPoint struct {
+		Attributes   []otlpAttribute `json:"attributes"`
+		TimeUnixNano string          `json:"timeUnixNano"`
+		AsDouble     float64         `json:"asDouble"`
+	}
+	otlpHistogram struct {
+		DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+		AggregationTemporality int                      `json:"aggregationTemporality"`
+	}
+	otlpHistogramDataPoint struct {
+		Attributes        []otlpAttribute `json:"attributes"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		TimeUnixNano      string          `json:"timeUnixNano"`
+		Count             string          `json:"count"`
+		Sum               float64         `json:"sum"`
+		Min               float64         `json:"min"`
+		Max               float64         `json:"max"`
+		BucketCounts      []string        `json:"bucketCounts"`
+		ExplicitBounds    []float64       `json:"explicitBounds"`
+	}
+
+	otlpLogsRequest struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpScopeLogs struct {
+		Scope      otlpScopeInfo   `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano         string          `json:"timeUnixNano"`
+		ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+		SeverityNumber       int             `json:"severityNumber"`
+		SeverityText         string          `json:"severityText"`
+		Body                 otlpValue       `json:"body"`
+		Attributes           []otlpAttribute `json:"attributes"`
+	}
+)
+
+func (b *otlpBackend) push(h *host, r *Result) error {
+	b.metrics.add(*r)
+	if r.Changed {
+		b.logs.add(*r)
+	}
+
+	return nil
+}
+
+// Export the metrics of a batch of results
+func (b *otlpBackend) exportMetrics(h *host, results []Result) error {
+	var metrics []*otlpMetric
+	byName := make(map[string]*otlpMetric)
+	metric := func(name string, description string, unit string) *otlpMetric {
+		m, ok := byName[name]
+		if !ok {
+			m = &otlpMetric{Name: name, Description: description, Unit: unit}
+			byName[name] = m
+			metrics = append(metrics, m)
+		}
+		return m
+	}
+	addGauge := func(m *otlpMetric, point otlpNumberDataPoint) {
+		if m.Gauge == nil {
+			m.Gauge = &otlpGauge{}
+		}
+		m.Gauge.DataPoints = append(m.Gauge.DataPoints, point)
+	}
+
+	for i := range results {
+		r := &results[i]
+		attributes := otlpResultAttributes(r)
+		end := r.Time.Add(r.Duration)
+		endNano := strconv.FormatInt(end.UnixNano(), 10)
+
+		addGauge(metric(otlpMetricPrefix+"up", "Whether the monitor is up (1) or down (0)", "1"), otlpNumberDataPoint{
+			Attributes:   attributes,
+			TimeUnixNano: endNano,
+			AsDouble:     statusValue(r.Status),
+		})
+
+		duration := metric(otlpMetricPrefix+"duration", "Time the monitor took to run", "s")
+		if duration.Histogram == nil {
+			duration.Histogram = &otlpHistogram{AggregationTemporality: otlpDeltaTemporal}
+		}
+		duration.Histogram.DataPoints = append(duration.Histogram.DataPoints, otlpDurationPoint(r, attributes, endNano))
+
+		names := make([]string, 0, len(r.Values))
+		for name := range r.Values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			//? JSON has no representation of NaN or infinity
+			value := r.Values[name]
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+
+			unit := ""
+			if strings.HasSuffix(name, "_percent") {
+				unit = "%"
+			} else if strings.HasSuffix(name, "_seconds") {
+				unit = "s"
+			}
+
+			addGauge(metric(otlpMetricPrefix+name, "", unit), otlpNumberDataPoint{
+				Attributes:   attributes,
+				TimeUnixNano: endNano,
+				AsDouble:     value,
+			})
+		}
+	}
+
+	return b.export(h, "v1/metrics", otlpMetricsRequest{
+		ResourceMetrics: []otlpResourceMetrics{{
+			Resource: b.resource,
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScopeInfo{Name: otlpScope},
+				Metrics: metrics,
+			}},
+		}},
+	})
+}
+
+// Export status changes of a batch of results as log records
+func (b *otlpBackend) exportLogs(h *host, results []Result) error {
+	records := make([]otlpLogRecord, len(results))
+	for i := range results {
+		r := &results[i]
+
+		record := otlpLogRecord{
+			TimeUnixNano:         strconv.FormatInt(r.Time.UnixNano(), 10),
+			ObservedTimeUnixNano: strconv.FormatInt(r.Time.Add(r.Duration).UnixNano(), 10),
+			SeverityNumber:       17,
+			SeverityText:         "ERROR",
+			Body:                 otlpValue{StringValue: newNotification(r).text()},
+			Attributes: append(otlpResultAttributes(r),
+				otlpAttribute{Key: "status", Value: otlpValue{StringValue: string(r.Status)}},
+				otlpAttribute{Key: "previous_status", Value: otlpValue{StringValue: string(r.PreviousStatus)}},
+				otlpAttribute{Key: "message", Value: otlpValue{StringValue: r.Message}},
+			),
+		}
+		if r.Status == StatusUp {
+			record.SeverityNumber = 9
+			record.SeverityText = "INFO"
+		}
+
+		records[i] = record
+	}
+
+	return b.export(h, "v1/logs", otlpLogsRequest{
+		ResourceLogs: []otlpResourceLogs{{
+			Resource: b.resource,
+			ScopeLogs: []otlpScopeLogs{{
+				Scope:      otlpScopeInfo{Name: otlpScope},
+				LogRecords: records,
+			}},
+		}},
+	})
+}
+
+// Send an export request to `path` of host `h`
+func (b *otlpBackend) export(h *host, path string, request any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		//? Encoding the same batch again would fail again
+		return &rejectedBatchError{err}
+	}
+
+	return h.tryURLs(func(hostURL string) error {
+		// Parse base URL
+		exportUrl, err := url.Parse(hostURL)
+		if err != nil {
+			return err
+		}
+
+		//? We already ensure that host ends with a trailing / in setupHost
+		exportUrl.Path += path
+
+		req, err := http.NewRequest(http.MethodPost, exportUrl.String(), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range b.headers {
+			req.Header.Set(name, value)
+		}
+
+		zap.S().Debugw("Exporting to OTLP endpoint",
+			"host", hostURL,
+			"url", req.URL.String(),
+		)
+
+		_, err = doPushRequest(req, h.timeout, &h.stats)
+		return err
+	})
+}
+
+// Data point of the duration histogram for a single run
+func otlpDurationPoint(r *Result, attributes []otlpAttribute, endNano string) otlpHistogramDataPoint {
+	seconds := r.Duration.Seconds()
+
+	bucketCounts := make([]string, len(otlpDurationBounds)+1)
+	bucket := sort.SearchFloat64s(otlpDurationBounds, seconds)
+	for i := range bucketCounts {
+		bucketCounts[i] = "0"
+	}
+	bucketCounts[bucket] = "1"
+
+	return otlpHistogramDataPoint{
+		Attributes:        attributes,
+		StartTimeUnixNano: strconv.FormatInt(r.Time.UnixNano(), 10),
+		TimeUnixNano:      endNano,
+		Count:             "1",
+		Sum:               seconds,
+		Min:               seconds,
+		Max:               seconds,
+		BucketCounts:      bucketCounts,
+		ExplicitBounds:    otlpDurationBounds,
+	}
+}
+
+// Attributes identifying the monitor of a result
+func otlpResultAttributes(r *Result) []otlpAttribute {
+	return []otlpAttribute{
+		{Key: "monitor", Value: otlpValue{StringValue: r.Monitor}},
+		{Key: "type", Value: otlpValue{StringValue: r.Type}},
+	}
+}
+
+// Setup the backend of a host of type 'otlp'
+func setupOTLPBackend(h *host, hostConfig *config.Host, nodeName string) *otlpBackend {
+	b := &otlpBackend{
+		headers: hostConfig.Headers,
+		resource: otlpResource{
+			Attributes: []otlpAttribute{
+				{Key: "service.name", Value: otlpValue{StringValue: otlpScope}},
+				{Key: "host.name", Value: otlpValue{StringValue: nodeName}},
+			},
+		},
+	}
+
+	labels := make([]string, 0, len(hostConfig.Labels))
+	for name := range hostConfig.Labels {
+		labels = append(labels, name)
+	}
+	sort.Strings(labels)
+	for _, name := range labels {
+		b.resource.Attributes = append(b.resource.Attributes, otlpAttribute{
+			Key:   name,
+			Value: otlpValue{StringValue: hostConfig.Labels[name]},
+		})
+	}
+
+	b.metrics = startBatcher(h.name, hostConfig.FlushInterval, hostConfig.BatchSize, func(results []Result) error {
+		return b.exportMetrics(h, results)
+	})
+	b.logs = startBatcher(h.name, hostConfig.FlushInterval, hostConfig.BatchSize, func(results []Result) error {
+		return b.exportLogs(h, results)
+	})
+
+	return b
+}
@@ -0,0 +1,63 @@
+package monitors
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+)
+
+func TestOTLPSkipsNonFiniteValues(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/v1/metrics" {
+			body, _ = io.ReadAll(req.Body)
+		}
+	}))
+	defer srv.Close()
+
+	h := setupHost(&config.Host{
+		Name: "otel",
+		Type: "otlp",
+		URL:  srv.URL,
+	}, "node")
+	b := h.backend.(*otlpBackend)
+
+	err := b.exportMetrics(h, []Result{{
+		Monitor: "Disk",
+		Type:    "disk_usage",
+		Status:  StatusUp,
+		Values: map[string]float64{
+			"nan_percent":  math.NaN(),
+			"inf_percent":  math.Inf(1),
+			"ninf_percent": math.Inf(-1),
+			"used_percent": 42,
+		},
+		Time: time.Now(),
+	}})
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	var request otlpMetricsRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		t.Fatalf("invalid body %q: %v", body, err)
+	}
+	names := make(map[string]bool)
+	for _, m := range request.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		names[m.Name] = true
+	}
+	for _, name := range []string{"nan_percent", "inf_percent", "ninf_percent"} {
+		if names[otlpMetricPrefix+name] {
+			t.Errorf("non-finite value %v was exported", name)
+		}
+	}
+	if !names[otlpMetricPrefix+"used_percent"] || !names[otlpMetricPrefix+"up"] {
+		t.Errorf("finite values are missing in %v", names)
+	}
+}
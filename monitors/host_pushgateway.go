@@ -0,0 +1,110 @@
+package monitors
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Default job name used in the grouping key of pushed metrics
+const defaultPushgatewayJob = "uptime_robot"
+
+// Pushes results as gauges to a Prometheus Pushgateway
+//
+// Every monitor uses its own group identified by the job, `node_name` and the
+// name of the monitor. Groups are replaced on every push, so values a monitor
+// no longer produces disappear as well.
+type pushgatewayBackend struct {
+	// Job name used in the grouping key
+	job string
+}
+
+func (b *pushgatewayBackend) push(h *host, r *Result) error {
+	return h.tryURLs(func(hostURL string) error {
+		return b.pushToURL(h, hostURL, r)
+	})
+}
+
+// Push the metrics of a single result to one URL of host `h`
+func (b *pushgatewayBackend) pushToURL(h *host, hostURL string, r *Result) error {
+	metrics := &prometheusMetrics{}
+	labels := []string{"type", r.Type}
+
+	metrics.add(
+		prometheusName("monitor_up"),
+		"gauge",
+		"Whether the monitor was up during its last run",
+		statusValue(r.Status),
+		labels...,
+	)
+	metrics.add(
+		prometheusName("monitor_duration_seconds"),
+		"gauge",
+		"Time the last run of the monitor took",
+		r.Duration.Seconds(),
+		labels...,
+	)
+	metrics.add(
+		prometheusName("monitor_last_run_timestamp_seconds"),
+		"gauge",
+		"Time the last run of the monitor started",
+		float64(r.Time.Unix()),
+		labels...,
+	)
+	metrics.addValues(r.Values, labels...)
+
+	var body bytes.Buffer
+	if err := metrics.writeTo(&body); err != nil {
+		return err
+	}
+
+	// Parse base URL
+	groupUrl, err := url.Parse(hostURL)
+	if err != nil {
+		return err
+	}
+
+	//? We already ensure that host ends with a trailing / in setupHost
+	groupUrl.Path += "metrics/job/" + b.job
+	groupUrl.Path += "/node@base64/" + encodePushgatewayLabel(r.NodeName)
+	groupUrl.Path += "/monitor@base64/" + encodePushgatewayLabel(r.Monitor)
+
+	req, err := http.NewRequest(http.MethodPut, groupUrl.String(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	zap.S().Debugw("Pushing to Pushgateway",
+		"host", hostURL,
+		"status", r.Status,
+		"url", req.URL.String(),
+	)
+
+	_, err = doPushRequest(req, h.timeout, &h.stats)
+	return err
+}
+
+// Encode a grouping key label value so it may contain any character
+func encodePushgatewayLabel(value string) string {
+	// Empty values have to be represented by a single '='
+	if value == "" {
+		return "="
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+// Setup the backend of a host of type 'pushgateway'
+func setupPushgatewayBackend(hostConfig *config.Host) *pushgatewayBackend {
+	b := &pushgatewayBackend{job: hostConfig.Job}
+	if b.job == "" {
+		b.job = defaultPushgatewayJob
+	}
+
+	return b
+}
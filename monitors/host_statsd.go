@@ -0,0 +1,187 @@
+package monitors
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Defaults of hosts of type 'statsd' and 'dogstatsd'
+const (
+	defaultStatsdPrefix = "uptime_robot"
+	// Seconds between two flushes, lower than for other batching backends as
+	// StatsD aggregates by time of arrival
+	defaultStatsdFlushInterval = 1
+	// Maximum size of a single datagram, fits into common MTUs
+	maxStatsdDatagram = 1432
+)
+
+// Sends gauges and timers of every run via UDP in StatsD or DogStatsD format
+//
+// Every run produces the gauge `up` (0 or 1), the timer `duration` and one
+// metric per value of the monitor. Values measured in seconds are sent as
+// timers in milliseconds without their unit suffix, all others as gauges.
+//
+// Plain StatsD does not know tags, so node and monitor are part of the metric
+// name (`{prefix}.{node}.{monitor}.up`). DogStatsD tags metrics with `node`,
+// `monitor` and `type` instead (`{prefix}.up`).
+//
+// Metrics are queued and sent by a background goroutine, so a slow or
+// unreachable agent never blocks the scheduler.
+type statsdBackend struct {
+	// Whether to send DogStatsD tags
+	dogstatsd bool
+	prefix    string
+
+	batcher *batcher[string]
+}
+
+func (b *statsdBackend) push(h *host, r *Result) error {
+	b.batcher.add(b.lines(r)...)
+	return nil
+}
+
+// Format all metrics of a result, one per line
+func (b *statsdBackend) lines(r *Result) []string {
+	prefix := b.prefix + "."
+	suffix := ""
+	if b.dogstatsd {
+		suffix = "|#" + strings.Join([]string{
+			"node:" + statsdTagValue(r.NodeName),
+			"monitor:" + statsdTagValue(r.Monitor),
+			"type:" + statsdTagValue(r.Type),
+		}, ",")
+	} else {
+		prefix += statsdName(r.NodeName) + "." + statsdName(r.Monitor) + "."
+	}
+
+	lines := []string{
+		prefix + "up:" + strconv.FormatFloat(statusValue(r.Status), 'f', -1, 64) + "|g" + suffix,
+		prefix + "duration:" + strconv.FormatFloat(float64(r.Duration.Microseconds())/1000, 'f', -1, 64) + "|ms" + suffix,
+	}
+
+	names := make([]string, 0, len(r.Values))
+	for name := range r.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := r.Values[name]
+
+		if strings.HasSuffix(name, "_seconds") {
+			name = strings.TrimSuffix(name, "_seconds")
+			lines = append(lines, prefix+statsdName(name)+":"+strconv.FormatFloat(value*1000, 'f', -1, 64)+"|ms"+suffix)
+		} else {
+			lines = append(lines, prefix+statsdName(name)+":"+strconv.FormatFloat(value, 'f', -1, 64)+"|g"+suffix)
+		}
+	}
+
+	return lines
+}
+
+// Send a batch of lines to host `h`, packing as many as possible into each
+// datagram
+func (b *statsdBackend) send(h *host, lines []string) error {
+	datagrams := []string{}
+	var datagram strings.Builder
+	for _, line := range lines {
+		if datagram.Len() > 0 && datagram.Len()+1+len(line) > maxStatsdDatagram {
+			datagrams = append(datagrams, datagram.String())
+			datagram.Reset()
+		}
+		if datagram.Len() > 0 {
+			datagram.WriteString("\n")
+		}
+		datagram.WriteString(line)
+	}
+	datagrams = append(datagrams, datagram.String())
+
+	return h.tryURLs(func(hostURL string) error {
+		agentUrl, err := url.Parse(hostURL)
+		if err != nil {
+			return err
+		}
+
+		address := agentUrl.Host
+		if agentUrl.Port() == "" {
+			address = net.JoinHostPort(agentUrl.Hostname(), "8125")
+		}
+
+		zap.S().Debugw("Sending to StatsD",
+			"host", h.name,
+			"address", address,
+			"lines", len(lines),
+			"datagrams", len(datagrams),
+		)
+
+		start := time.Now()
+		err = writeDatagrams(address, datagrams, h.timeout)
+		h.stats.record(time.Since(start), err)
+
+		return err
+	})
+}
+
+// Write datagrams to `address` via UDP
+func writeDatagrams(address string, datagrams []string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	for _, datagram := range datagrams {
+		if _, err := conn.Write([]byte(datagram)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Convert a name to a single segment of a StatsD metric name
+func statsdName(name string) string {
+	var b strings.Builder
+	for _, c := range name {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-' {
+			b.WriteRune(c)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+
+	return b.String()
+}
+
+// Remove characters that are not allowed in DogStatsD tag values
+func statsdTagValue(value string) string {
+	return strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_").Replace(value)
+}
+
+// Setup the backend of a host of type 'statsd' or 'dogstatsd'
+func setupStatsdBackend(h *host, hostConfig *config.Host) *statsdBackend {
+	b := &statsdBackend{
+		dogstatsd: h.hostType == "dogstatsd",
+		prefix:    strings.TrimSuffix(hostConfig.Prefix, "."),
+	}
+	if b.prefix == "" {
+		b.prefix = defaultStatsdPrefix
+	}
+
+	flushInterval := hostConfig.FlushInterval
+	if flushInterval == 0 {
+		flushInterval = defaultStatsdFlushInterval
+	}
+	b.batcher = startBatcher(h.name, flushInterval, hostConfig.BatchSize, func(lines []string) error {
+		return b.send(h, lines)
+	})
+
+	return b
+}
@@ -0,0 +1,295 @@
+package monitors
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Identifiers used in syslog messages
+const (
+	syslogAppName = "uptime-robot"
+	// SD-IDs use the private enterprise number reserved for documentation
+	syslogResultID = "result@32473"
+	syslogValuesID = "values@32473"
+)
+
+// Syslog facilities by name
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Syslog severities by name
+var syslogSeverities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3,
+	"warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// Default severities by status
+var defaultSyslogSeverities = map[monitorStatus]string{
+	StatusUp:   "info",
+	StatusDown: "err",
+}
+
+// Sends results as RFC 5424 messages to a syslog server
+//
+// Name, type, status and values of the monitor are included as structured
+// data. Supported transports are UDP (one message per datagram), TCP and TLS
+// (octet counting framing as described in RFC 6587 and RFC 5425). Connections
+// are kept open and re-established once they fail.
+type syslogBackend struct {
+	facility int
+	// Severity by status
+	severities map[monitorStatus]int
+	// HOSTNAME of all messages
+	hostname string
+	procID   string
+
+	mu   sync.Mutex
+	conn net.Conn
+	// Whether conn is a stream, requiring framing
+	stream bool
+}
+
+func (b *syslogBackend) push(h *host, r *Result) error {
+	message := b.format(r)
+
+	start := time.Now()
+	err := b.send(h, message)
+	h.stats.record(time.Since(start), err)
+
+	return err
+}
+
+// Send a single message, connecting if necessary
+func (b *syslogBackend) send(h *host, message []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Reuse the existing connection, reconnecting if the server closed it
+	if b.conn != nil {
+		if err := b.write(h, message); err == nil {
+			return nil
+		}
+	}
+
+	return h.tryURLs(func(hostURL string) error {
+		if err := b.connect(h, hostURL); err != nil {
+			return err
+		}
+
+		return b.write(h, message)
+	})
+}
+
+// Connect to the syslog server at `hostURL`, replacing any existing connection
+//
+// Must be called with b.mu held
+func (b *syslogBackend) connect(h *host, hostURL string) error {
+	if b.conn != nil {
+		b.conn.Close()
+		b.conn = nil
+	}
+
+	serverUrl, err := url.Parse(hostURL)
+	if err != nil {
+		return err
+	}
+
+	address := serverUrl.Host
+	if serverUrl.Port() == "" {
+		port := "514"
+		if serverUrl.Scheme == "tls" {
+			port = "6514"
+		}
+		address = net.JoinHostPort(serverUrl.Hostname(), port)
+	}
+
+	zap.S().Debugw("Connecting to syslog server",
+		"host", h.name,
+		"address", address,
+		"transport", serverUrl.Scheme,
+	)
+
+	dialer := &net.Dialer{Timeout: h.timeout}
+	switch serverUrl.Scheme {
+	case "udp":
+		b.conn, err = dialer.Dial("udp", address)
+		b.stream = false
+	case "tcp":
+		b.conn, err = dialer.Dial("tcp", address)
+		b.stream = true
+	case "tls":
+		b.conn, err = tls.DialWithDialer(dialer, "tcp", address, nil)
+		b.stream = true
+	default:
+		return fmt.Errorf("unsupported syslog transport %q", serverUrl.Scheme)
+	}
+
+	return err
+}
+
+// Write a single message to the current connection, closing it on errors
+//
+// Must be called with b.mu held
+func (b *syslogBackend) write(h *host, message []byte) error {
+	if b.stream {
+		message = append([]byte(strconv.Itoa(len(message))+" "), message...)
+	}
+
+	b.conn.SetWriteDeadline(time.Now().Add(h.timeout))
+	if _, err := b.conn.Write(message); err != nil {
+		b.conn.Close()
+		b.conn = nil
+		return err
+	}
+
+	return nil
+}
+
+// Format a result as RFC 5424 message
+func (b *syslogBackend) format(r *Result) []byte {
+	severity, ok := b.severities[r.Status]
+	if !ok {
+		severity = syslogSeverities["notice"]
+	}
+
+	msgID := "result"
+	if r.Changed {
+		msgID = "change"
+	}
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "<%d>1 %s %s %s %s %s ",
+		b.facility*8+severity,
+		r.Time.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		b.hostname,
+		syslogAppName,
+		b.procID,
+		msgID,
+	)
+
+	message.WriteString("[" + syslogResultID)
+	params := [][2]string{
+		{"monitor", r.Monitor},
+		{"type", r.Type},
+		{"status", string(r.Status)},
+		{"ping", strconv.Itoa(r.Ping)},
+		{"duration", strconv.FormatFloat(r.Duration.Seconds(), 'f', -1, 64)},
+	}
+	if r.Key != "" {
+		params = append(params, [2]string{"key", r.Key})
+	}
+	if r.PreviousStatus != "" {
+		params = append(params, [2]string{"previousStatus", string(r.PreviousStatus)})
+	}
+	for _, param := range params {
+		writeSyslogParam(&message, param[0], param[1])
+	}
+	message.WriteString("]")
+
+	if len(r.Values) > 0 {
+		names := make([]string, 0, len(r.Values))
+		for name := range r.Values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		message.WriteString("[" + syslogValuesID)
+		for _, name := range names {
+			writeSyslogParam(&message, name, strconv.FormatFloat(r.Values[name], 'f', -1, 64))
+		}
+		message.WriteString("]")
+	}
+
+	if r.Message != "" {
+		// Marks the message as UTF-8
+		message.WriteString(" \xEF\xBB\xBF" + r.Message)
+	}
+
+	return []byte(message.String())
+}
+
+// Write a single SD-PARAM
+func writeSyslogParam(message *strings.Builder, name string, value string) {
+	message.WriteString(" " + syslogName(name, 32) + `="`)
+	for _, c := range value {
+		if c == '"' || c == '\\' || c == ']' {
+			message.WriteRune('\\')
+		}
+		message.WriteRune(c)
+	}
+	message.WriteString(`"`)
+}
+
+// Convert a name to printable US-ASCII as required for header fields and
+// SD-NAMEs, shortening it to at most `max` characters
+func syslogName(name string, max int) string {
+	var b strings.Builder
+	for _, c := range name {
+		if b.Len() == max {
+			break
+		}
+		if c < 33 || c > 126 || c == '=' || c == ']' || c == '"' {
+			c = '_'
+		}
+		b.WriteRune(c)
+	}
+
+	if b.Len() == 0 {
+		return "-"
+	}
+	return b.String()
+}
+
+// Setup the backend of a host of type 'syslog'
+func setupSyslogBackend(hostConfig *config.Host, nodeName string) *syslogBackend {
+	b := &syslogBackend{
+		severities: make(map[monitorStatus]int),
+		hostname:   syslogName(nodeName, 255),
+		procID:     strconv.Itoa(os.Getpid()),
+	}
+
+	facilityName := hostConfig.Facility
+	if facilityName == "" {
+		facilityName = "daemon"
+	}
+	facility, ok := syslogFacilities[facilityName]
+	if !ok {
+		zap.S().Panicw("Unknown syslog facility",
+			"name", hostConfig.Name,
+			"facility", facilityName,
+		)
+	}
+	b.facility = facility
+
+	for status, severity := range defaultSyslogSeverities {
+		b.severities[status] = syslogSeverities[severity]
+	}
+	for status, severity := range hostConfig.Severities {
+		value, ok := syslogSeverities[severity]
+		if !ok {
+			zap.S().Panicw("Unknown syslog severity",
+				"name", hostConfig.Name,
+				"status", status,
+				"severity", severity,
+			)
+		}
+		b.severities[monitorStatus(status)] = value
+	}
+
+	return b
+}
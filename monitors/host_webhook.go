@@ -0,0 +1,159 @@
+package monitors
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Default header containing the signature of a webhook body
+const defaultSignatureHeader = "X-Uptime-Robot-Signature"
+
+// Sends a JSON document for every result to an arbitrary URL
+//
+// The body is rendered from a template of the host, defaulting to a JSON
+// representation of the result. If a secret is configured, the body is signed
+// using HMAC-SHA256 so receivers can authenticate it.
+type webhookBackend struct {
+	method  string
+	headers map[string]*template.Template
+	// nil to send the default JSON representation
+	body *template.Template
+
+	// Empty if bodies should not be signed
+	secret          string
+	signatureHeader string
+}
+
+// JSON representation of a result, used as default body of a webhook and in
+// journals
+type resultPayload struct {
+	Node                    string             `json:"node"`
+	Monitor                 string             `json:"monitor"`
+	Type                    string             `json:"type"`
+	Key                     string             `json:"key"`
+	Status                  monitorStatus      `json:"status"`
+	PreviousStatus          monitorStatus      `json:"previous_status"`
+	Changed                 bool               `json:"changed"`
+	PreviousDurationSeconds float64            `json:"previous_duration_seconds"`
+	Message                 string             `json:"message"`
+	Ping                    int                `json:"ping"`
+	DurationSeconds         float64            `json:"duration_seconds"`
+	Values                  map[string]float64 `json:"values"`
+	Time                    time.Time          `json:"time"`
+	RunID                   string             `json:"run_id"`
+}
+
+func (b *webhookBackend) push(h *host, r *Result) error {
+	body, err := b.render(r)
+	if err != nil {
+		zap.S().DPanicw("Could not render webhook body",
+			"host", h.name,
+			"error", err.Error(),
+		)
+		return err
+	}
+
+	return h.tryURLs(func(hostURL string) error {
+		req, err := http.NewRequest(b.method, hostURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		for name, tmpl := range b.headers {
+			value, err := renderTemplate(tmpl, r)
+			if err != nil {
+				return err
+			}
+			req.Header.Set(name, value)
+		}
+
+		if b.secret != "" {
+			req.Header.Set(b.signatureHeader, "sha256="+signHMACSHA256(b.secret, body))
+		}
+
+		zap.S().Debugw("Sending webhook",
+			"host", hostURL,
+			"key", r.Key,
+			"status", r.Status,
+			"message", r.Message,
+		)
+
+		_, err = doPushRequest(req, h.timeout, &h.stats)
+		return err
+	})
+}
+
+// Render the body of the webhook for `r`
+func (b *webhookBackend) render(r *Result) ([]byte, error) {
+	if b.body != nil {
+		body, err := renderTemplate(b.body, r)
+		return []byte(body), err
+	}
+
+	return json.Marshal(newResultPayload(r))
+}
+
+// Convert a result to its JSON representation
+func newResultPayload(r *Result) resultPayload {
+	return resultPayload{
+		Node:                    r.NodeName,
+		Monitor:                 r.Monitor,
+		Type:                    r.Type,
+		Key:                     r.Key,
+		Status:                  r.Status,
+		PreviousStatus:          r.PreviousStatus,
+		Changed:                 r.Changed,
+		PreviousDurationSeconds: r.PreviousDuration.Seconds(),
+		Message:                 r.Message,
+		Ping:                    r.Ping,
+		DurationSeconds:         r.Duration.Seconds(),
+		Values:                  r.Values,
+		Time:                    r.Time,
+		RunID:                   r.RunID,
+	}
+}
+
+// Sign `body` with `secret` returning the hex encoded HMAC-SHA256
+func signHMACSHA256(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Setup the backend of a host of type 'webhook'
+func setupWebhookBackend(hostConfig *config.Host) *webhookBackend {
+	b := &webhookBackend{
+		method:          strings.ToUpper(hostConfig.Method),
+		headers:         make(map[string]*template.Template),
+		secret:          hostConfig.Secret,
+		signatureHeader: hostConfig.SignatureHeader,
+	}
+
+	if b.method == "" {
+		b.method = http.MethodPost
+	}
+	if b.signatureHeader == "" {
+		b.signatureHeader = defaultSignatureHeader
+	}
+
+	for name, text := range hostConfig.Headers {
+		b.headers[name] = parseHostTemplate(hostConfig, "headers."+name, text)
+	}
+	if hostConfig.Body != "" {
+		b.body = parseHostTemplate(hostConfig, "body", hostConfig.Body)
+	}
+
+	return b
+}
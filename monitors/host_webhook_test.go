@@ -0,0 +1,79 @@
+package monitors
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+)
+
+func TestWebhookPushesSignedResult(t *testing.T) {
+	var body []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ = io.ReadAll(req.Body)
+		signature = req.Header.Get(defaultSignatureHeader)
+	}))
+	defer srv.Close()
+
+	h := setupHost(&config.Host{
+		Name:   "hook",
+		Type:   "webhook",
+		URL:    srv.URL + "/hook",
+		Secret: "s3cret",
+	}, "node")
+
+	r := &Result{
+		NodeName:         "node",
+		Monitor:          "Disk",
+		Type:             "disk_usage",
+		Status:           StatusDown,
+		PreviousStatus:   StatusUp,
+		Changed:          true,
+		PreviousDuration: 90 * time.Minute,
+		Message:          "Exceeds threshold",
+		Duration:         2 * time.Second,
+		Values:           map[string]float64{"usage_percent": 97},
+		Time:             time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err := h.backend.push(h, r); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+
+	// Computed independently of the signing code under test
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+
+	var payload resultPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("invalid body %q: %v", body, err)
+	}
+	if payload.Monitor != "Disk" || payload.Status != StatusDown || payload.PreviousStatus != StatusUp || !payload.Changed {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+	if payload.PreviousDurationSeconds != 5400 {
+		t.Errorf("previous_duration_seconds = %v, want 5400", payload.PreviousDurationSeconds)
+	}
+	if payload.DurationSeconds != 2 || payload.Values["usage_percent"] != 97 {
+		t.Errorf("unexpected measurements %+v", payload)
+	}
+}
+
+func TestSignHMACSHA256(t *testing.T) {
+	// Well-known HMAC-SHA256 test vector
+	got := signHMACSHA256("key", []byte("The quick brown fox jumps over the lazy dog"))
+	want := "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+	if got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+}
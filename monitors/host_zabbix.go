@@ -0,0 +1,260 @@
+package monitors
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Zabbix protocol header flags
+const (
+	zabbixProtocol   byte = 0x01
+	zabbixCompressed byte = 0x02
+	zabbixLarge      byte = 0x04
+)
+
+// Maximum size of a response accepted from a Zabbix server
+const maxZabbixResponse = 16 << 20
+
+// Parses the info of a sender data response
+var zabbixInfoRegexp = regexp.MustCompile(`processed: (\d+); failed: (\d+); total: (\d+)`)
+
+// Delivers results in batches as trapper items using the Zabbix sender protocol
+//
+// Every result produces the items `{key}.status` (1 if up, 0 otherwise),
+// `{key}.message`, `{key}.ping`, `{key}.duration` (seconds) and one item
+// `{key}.{value}` per value of the monitor. Items not known to Zabbix are
+// reported as failed by the server and logged, but not retried.
+type zabbixBackend struct {
+	// Host in Zabbix the items belong to
+	zabbixHost string
+
+	batcher *batcher[zabbixItem]
+}
+
+// A single item value as sent to Zabbix
+type zabbixItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+	NS    int    `json:"ns"`
+}
+
+// Request sending item values to Zabbix
+type zabbixRequest struct {
+	Request string       `json:"request"`
+	Data    []zabbixItem `json:"data"`
+	Clock   int64        `json:"clock"`
+	NS      int          `json:"ns"`
+}
+
+// Response of Zabbix to a sender data request
+type zabbixResponse struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+func (b *zabbixBackend) push(h *host, r *Result) error {
+	b.batcher.add(b.items(r)...)
+	return nil
+}
+
+// Convert a result to item values
+func (b *zabbixBackend) items(r *Result) []zabbixItem {
+	values := [][2]string{
+		{"status", strconv.FormatFloat(statusValue(r.Status), 'f', -1, 64)},
+		{"message", r.Message},
+		{"ping", strconv.Itoa(r.Ping)},
+		{"duration", strconv.FormatFloat(r.Duration.Seconds(), 'f', -1, 64)},
+	}
+
+	names := make([]string, 0, len(r.Values))
+	for name := range r.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values = append(values, [2]string{name, strconv.FormatFloat(r.Values[name], 'f', -1, 64)})
+	}
+
+	items := make([]zabbixItem, len(values))
+	for i, value := range values {
+		items[i] = zabbixItem{
+			Host:  b.zabbixHost,
+			Key:   r.Key + "." + value[0],
+			Value: value[1],
+			Clock: r.Time.Unix(),
+			NS:    r.Time.Nanosecond(),
+		}
+	}
+
+	return items
+}
+
+// Send a batch of item values to host `h`
+func (b *zabbixBackend) send(h *host, items []zabbixItem) error {
+	now := time.Now()
+	data, err := json.Marshal(zabbixRequest{
+		Request: "sender data",
+		Data:    items,
+		Clock:   now.Unix(),
+		NS:      now.Nanosecond(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var response *zabbixResponse
+	err = h.tryURLs(func(hostURL string) error {
+		serverUrl, err := url.Parse(hostURL)
+		if err != nil {
+			return err
+		}
+
+		address := serverUrl.Host
+		if serverUrl.Port() == "" {
+			address = net.JoinHostPort(serverUrl.Hostname(), "10051")
+		}
+
+		zap.S().Debugw("Sending to Zabbix",
+			"host", h.name,
+			"address", address,
+			"items", len(items),
+		)
+
+		start := time.Now()
+		response, err = exchangeZabbix(address, data, h.timeout)
+		if err == nil && response.Response != "success" {
+			err = fmt.Errorf("zabbix responded with %q: %v", response.Response, response.Info)
+		}
+		h.stats.record(time.Since(start), err)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	processed, failed, total, ok := parseZabbixInfo(response.Info)
+	if !ok {
+		zap.S().Debugw("Could not parse Zabbix response",
+			"host", h.name,
+			"info", response.Info,
+		)
+		return nil
+	}
+	if failed > 0 {
+		//? Retrying would fail again, as Zabbix rejects items it does not know
+		return &rejectedBatchError{fmt.Errorf("zabbix failed to process %v of %v items (%v processed)", failed, total, processed)}
+	}
+
+	return nil
+}
+
+// Send a single request to the Zabbix server at `address` and read its response
+func exchangeZabbix(address string, data []byte, timeout time.Duration) (*zabbixResponse, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	packet := append([]byte("ZBXD"), zabbixProtocol)
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(len(data)))
+	// Reserved, uncompressed size if compressed
+	packet = binary.LittleEndian.AppendUint32(packet, 0)
+	packet = append(packet, data...)
+
+	if _, err := conn.Write(packet); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != "ZBXD" {
+		return nil, errors.New("invalid Zabbix response header")
+	}
+
+	flags := header[4]
+	if flags&zabbixCompressed != 0 {
+		return nil, errors.New("compressed Zabbix responses are not supported")
+	}
+
+	var length uint64
+	if flags&zabbixLarge != 0 {
+		lengths := make([]byte, 16)
+		if _, err := io.ReadFull(reader, lengths); err != nil {
+			return nil, err
+		}
+		length = binary.LittleEndian.Uint64(lengths)
+	} else {
+		lengths := make([]byte, 8)
+		if _, err := io.ReadFull(reader, lengths); err != nil {
+			return nil, err
+		}
+		length = uint64(binary.LittleEndian.Uint32(lengths))
+	}
+	if length > maxZabbixResponse {
+		return nil, fmt.Errorf("zabbix response of %v bytes is too large", length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+
+	response := &zabbixResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		return nil, fmt.Errorf("invalid Zabbix response: %w", err)
+	}
+
+	return response, nil
+}
+
+// Parse the number of processed, failed and total items from the info of a
+// response
+func parseZabbixInfo(info string) (processed int, failed int, total int, ok bool) {
+	match := zabbixInfoRegexp.FindStringSubmatch(info)
+	if match == nil {
+		return 0, 0, 0, false
+	}
+
+	processed, _ = strconv.Atoi(match[1])
+	failed, _ = strconv.Atoi(match[2])
+	total, _ = strconv.Atoi(match[3])
+
+	return processed, failed, total, true
+}
+
+// Setup the backend of a host of type 'zabbix'
+func setupZabbixBackend(h *host, hostConfig *config.Host, nodeName string) *zabbixBackend {
+	b := &zabbixBackend{
+		zabbixHost: hostConfig.ZabbixHost,
+	}
+	if b.zabbixHost == "" {
+		b.zabbixHost = nodeName
+	}
+
+	b.batcher = startBatcher(h.name, hostConfig.FlushInterval, hostConfig.BatchSize, func(items []zabbixItem) error {
+		return b.send(h, items)
+	})
+
+	return b
+}
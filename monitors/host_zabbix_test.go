@@ -0,0 +1,148 @@
+package monitors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+)
+
+// Fake Zabbix server answering every sender request with `info`
+//
+// Received requests are sent to the returned channel.
+func startFakeZabbix(t *testing.T, info string) (string, <-chan zabbixRequest) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	requests := make(chan zabbixRequest, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			header := make([]byte, 13)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				t.Errorf("could not read header: %v", err)
+				conn.Close()
+				continue
+			}
+			if !bytes.Equal(header[:5], []byte("ZBXD\x01")) {
+				t.Errorf("invalid header %q", header[:5])
+			}
+			if reserved := binary.LittleEndian.Uint32(header[9:]); reserved != 0 {
+				t.Errorf("reserved = %v, want 0", reserved)
+			}
+
+			body := make([]byte, binary.LittleEndian.Uint32(header[5:9]))
+			if _, err := io.ReadFull(conn, body); err != nil {
+				t.Errorf("could not read body of %v bytes: %v", len(body), err)
+			}
+			var request zabbixRequest
+			if err := json.Unmarshal(body, &request); err != nil {
+				t.Errorf("invalid body %q: %v", body, err)
+			}
+			requests <- request
+
+			response, _ := json.Marshal(zabbixResponse{Response: "success", Info: info})
+			packet := append([]byte("ZBXD\x01"), binary.LittleEndian.AppendUint32(nil, uint32(len(response)))...)
+			packet = append(packet, 0, 0, 0, 0)
+			conn.Write(append(packet, response...))
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().String(), requests
+}
+
+func TestZabbixSendsItems(t *testing.T) {
+	address, requests := startFakeZabbix(t, "processed: 5; failed: 0; total: 5; seconds spent: 0.000055")
+
+	h := setupHost(&config.Host{
+		Name:          "zabbix",
+		Type:          "zabbix",
+		URL:           "tcp://" + address,
+		FlushInterval: 3600,
+	}, "node")
+	b := h.backend.(*zabbixBackend)
+
+	items := b.items(&Result{
+		Key:    "disk",
+		Status: StatusUp,
+		Values: map[string]float64{"usage_percent": 68},
+		Time:   time.Unix(1700000000, 5),
+	})
+	if err := b.send(h, items); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	request := <-requests
+	if request.Request != "sender data" {
+		t.Errorf("request = %q, want sender data", request.Request)
+	}
+	if len(request.Data) != 5 {
+		t.Fatalf("got %v items, want 5", len(request.Data))
+	}
+	item := request.Data[0]
+	if item.Host != "node" || item.Key != "disk.status" || item.Value != "1" || item.Clock != 1700000000 || item.NS != 5 {
+		t.Errorf("unexpected item %+v", item)
+	}
+	if last := request.Data[4]; last.Key != "disk.usage_percent" || last.Value != "68" {
+		t.Errorf("unexpected value item %+v", last)
+	}
+}
+
+func TestZabbixFailedItemsAreAnError(t *testing.T) {
+	address, _ := startFakeZabbix(t, "processed: 3; failed: 2; total: 5; seconds spent: 0.000055")
+
+	h := setupHost(&config.Host{
+		Name:          "zabbix",
+		Type:          "zabbix",
+		URL:           "tcp://" + address,
+		FlushInterval: 3600,
+	}, "node")
+	b := h.backend.(*zabbixBackend)
+
+	err := b.send(h, b.items(&Result{Key: "disk", Status: StatusDown}))
+	if err == nil {
+		t.Fatal("expected an error for failed items")
+	}
+	if !isRejectedBatch(err) {
+		t.Errorf("failed items should not be retried, got %v", err)
+	}
+	var rejected *rejectedBatchError
+	if !errors.As(err, &rejected) {
+		t.Errorf("unexpected error type %T", err)
+	}
+}
+
+func TestParseZabbixInfo(t *testing.T) {
+	tests := []struct {
+		info                     string
+		processed, failed, total int
+		ok                       bool
+	}{
+		{"processed: 5; failed: 0; total: 5; seconds spent: 0.000055", 5, 0, 5, true},
+		{"processed: 1; failed: 12; total: 13; seconds spent: 0.1", 1, 12, 13, true},
+		{"garbage", 0, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		processed, failed, total, ok := parseZabbixInfo(test.info)
+		if processed != test.processed || failed != test.failed || total != test.total || ok != test.ok {
+			t.Errorf("parseZabbixInfo(%q) = %v, %v, %v, %v", test.info, processed, failed, total, ok)
+		}
+	}
+}
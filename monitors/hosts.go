@@ -0,0 +1,318 @@
+package monitors
+
+import (
+	"sync"
+	"time"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Default time in seconds an URL of a host is skipped after it failed
+const defaultFailbackAfter = 60
+
+// Delivers results to a host, implemented once per host type
+//
+// Backends are responsible for choosing one of the URLs of the host, usually
+// using `h.tryURLs` to fail over between them.
+type backend interface {
+	// Push a single result to host `h`
+	push(h *host, r *Result) error
+}
+
+// Implemented by backends that want to be notified before a monitor runs
+type startNotifier interface {
+	// Signal the start of a run to host `h`
+	//
+	// Only `r.NodeName`, `r.Monitor`, `r.Type`, `r.Key`, `r.RunID` and `r.Time`
+	// are set at this point.
+	start(h *host, r *Result) error
+}
+
+// Optional interface of backends that can create monitors on their host
+type monitorProvisioner interface {
+	// Provision monitors that did not define a key on host `h`
+	provision(h *host, monitors []provisionedMonitor)
+}
+
+// Monitor to be created on a host
+type provisionedMonitor struct {
+	name string
+	// Longest time expected between two pushes of the monitor
+	maxGap time.Duration
+}
+
+// An uptime host results are pushed to
+//
+// A host can define multiple URLs in priority order. Pushes always go to the
+// first healthy URL, failing over to the next one on errors and failing back
+// once a higher priority URL recovers.
+type host struct {
+	// Name of this host (user defined in config)
+	name string
+	// Type of this host, defaults to Uptime-Kuma
+	hostType string
+	// Delivers results depending on the type of this host
+	backend backend
+	// Whether monitors have to define a key for this host
+	requiresKey bool
+	// Whether only results that changed the status are pushed
+	onlyChanges bool
+	// URLs in priority order, each ending with a trailing '/'
+	urls []string
+	// How long an URL is skipped after it failed
+	failbackAfter time.Duration
+	// Time a single push request may take
+	timeout time.Duration
+	// Statistics of all pushes to this host
+	stats pushStats
+
+	mu sync.Mutex
+	// Time until which the URL at the same index is considered unhealthy
+	unhealthyUntil []time.Time
+	// Index of the URL that was last pushed to successfully
+	active int
+}
+
+// Order in which the URLs of this host should be tried
+//
+// Healthy URLs come first in priority order, followed by the unhealthy ones so
+// that a push is still attempted if all URLs recently failed.
+func (h *host) candidates() []int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	healthy := make([]int, 0, len(h.urls))
+	unhealthy := make([]int, 0, len(h.urls))
+	for i := range h.urls {
+		if now.Before(h.unhealthyUntil[i]) {
+			unhealthy = append(unhealthy, i)
+		} else {
+			healthy = append(healthy, i)
+		}
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// Record the outcome of pushing to the URL at index `i`
+func (h *host) report(i int, healthy bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !healthy {
+		h.unhealthyUntil[i] = time.Now().Add(h.failbackAfter)
+		return
+	}
+
+	h.unhealthyUntil[i] = time.Time{}
+	if h.active != i {
+		zap.S().Infow("Switching host URL",
+			"host", h.name,
+			"old_url", h.urls[h.active],
+			"new_url", h.urls[i],
+		)
+		h.active = i
+	}
+}
+
+// Setup all hosts defined in the config, indexed by their name
+func setupHosts(c config.Config) map[string]*host {
+	hosts := make(map[string]*host, len(c.Hosts))
+
+	for i := range c.Hosts {
+		hostConfig := &c.Hosts[i]
+
+		if _, ok := hosts[hostConfig.Name]; ok {
+			zap.S().Panicw("Host is not unique",
+				"host", hostConfig.Name,
+			)
+		}
+
+		hosts[hostConfig.Name] = setupHost(hostConfig, c.NodeName)
+	}
+
+	return hosts
+}
+
+// Setup a single host
+func setupHost(hostConfig *config.Host, nodeName string) *host {
+	urls := append([]string{}, hostConfig.URLs...)
+	if hostConfig.URL != "" {
+		urls = append([]string{hostConfig.URL}, urls...)
+	}
+	//? Journals write to a local file instead
+	if len(urls) == 0 && hostConfig.Type != "journal" {
+		zap.S().Panicw("Missing paramter for host",
+			"name", hostConfig.Name,
+			"paramter", "url",
+		)
+	}
+
+	h := &host{
+		name:           hostConfig.Name,
+		hostType:       hostConfig.Type,
+		onlyChanges:    hostConfig.OnlyChanges,
+		urls:           urls,
+		failbackAfter:  time.Duration(hostConfig.FailbackAfter) * time.Second,
+		unhealthyUntil: make([]time.Time, len(urls)),
+		timeout:        time.Duration(hostConfig.Timeout) * time.Second,
+	}
+	if hostConfig.FailbackAfter == 0 {
+		h.failbackAfter = defaultFailbackAfter * time.Second
+	}
+	if hostConfig.Timeout == 0 {
+		h.timeout = defaultPushTimeout * time.Second
+	}
+	if h.hostType == "" {
+		h.hostType = "kuma"
+	}
+
+	// Most host types append a path to the URL
+	exactURLs := false
+
+	// Setup based on host type
+	switch h.hostType {
+	case "kuma":
+		h.backend = setupKumaBackend(hostConfig, nodeName)
+		//? Keys of provisioned monitors are looked up by the backend
+		h.requiresKey = !hostConfig.Provision
+	case "healthchecks":
+		h.backend = setupHealthchecksBackend(hostConfig)
+		h.requiresKey = true
+	case "pushgateway":
+		h.backend = setupPushgatewayBackend(hostConfig)
+	case "influxdb":
+		h.backend = setupInfluxBackend(h, hostConfig)
+	case "mqtt":
+		h.backend = setupMQTTBackend(h, hostConfig, nodeName)
+		//? Only the address of the broker is used, which also leaves the URLs
+		//? untouched while the backend already connects
+		exactURLs = true
+	case "webhook":
+		h.backend = setupWebhookBackend(hostConfig)
+		exactURLs = true
+	case "slack", "discord", "teams", "mattermost":
+		h.backend = setupChatBackend(hostConfig, h.hostType)
+		//? Notifiers only ever report changes
+		h.onlyChanges = true
+		exactURLs = true
+	case "syslog":
+		h.backend = setupSyslogBackend(hostConfig, nodeName)
+		exactURLs = true
+	case "statsd", "dogstatsd":
+		h.backend = setupStatsdBackend(h, hostConfig)
+		exactURLs = true
+	case "zabbix":
+		h.backend = setupZabbixBackend(h, hostConfig, nodeName)
+		h.requiresKey = true
+		exactURLs = true
+	case "icinga2":
+		h.backend = setupIcingaBackend(hostConfig)
+		h.requiresKey = true
+	case "otlp":
+		h.backend = setupOTLPBackend(h, hostConfig, nodeName)
+	case "graphite":
+		h.backend = setupGraphiteBackend(h, hostConfig)
+		exactURLs = true
+	case "pagerduty", "opsgenie":
+		h.backend = setupIncidentBackend(hostConfig, h.hostType)
+	case "alertmanager":
+		h.backend = setupAlertmanagerBackend(h, hostConfig)
+	case "email":
+		h.backend = setupEmailBackend(hostConfig)
+		h.onlyChanges = true
+		exactURLs = true
+	case "journal":
+		h.backend = setupJournalBackend(hostConfig)
+	case "ntfy":
+		h.backend = setupNtfyBackend(hostConfig)
+		h.onlyChanges = true
+	case "gotify":
+		h.backend = setupGotifyBackend(hostConfig)
+		h.onlyChanges = true
+	default:
+		zap.S().Panicw("Unknown host type",
+			"name", hostConfig.Name,
+			"type", hostConfig.Type,
+		)
+	}
+
+	for i, hostURL := range h.urls {
+		// Ensure host ends with a trailing '/'
+		if !exactURLs && hostURL[len(hostURL)-1:] != "/" {
+			zap.S().Debugw("Adding trailing '/' to host url",
+				"host", hostConfig.Name,
+				"old_url", hostURL,
+			)
+			h.urls[i] = hostURL + "/"
+		}
+	}
+
+	zap.S().Debugw("Setup host",
+		"name", h.name,
+		"type", h.hostType,
+		"urls", h.urls,
+		"failback_after", h.failbackAfter,
+	)
+
+	return h
+}
+
+// Pushes a monitors state to an uptime host using the backend of its type
+func pushToHost(h *host, r *Result) error {
+	return h.backend.push(h, r)
+}
+
+// Signal the start of a run to an uptime host if its backend supports it
+func pushStartToHost(h *host, r *Result) error {
+	notifier, ok := h.backend.(startNotifier)
+	if !ok {
+		return nil
+	}
+
+	return notifier.start(h, r)
+}
+
+// Provision monitors without a key on an uptime host if its backend supports it
+func provisionMonitorsOnHost(h *host, monitors []provisionedMonitor) {
+	if provisioner, ok := h.backend.(monitorProvisioner); ok {
+		provisioner.provision(h, monitors)
+	}
+}
+
+// Call `fn` with the URLs of this host in order until one succeeds
+//
+// If the host defines multiple URLs, healthy ones are tried in priority order
+// before the ones that recently failed. Only errors reaching a URL and 5xx
+// responses mark it unhealthy and fail over to the next one, a 4xx response is
+// returned right away.
+//
+// Returns the error of the last URL tried if none succeeded
+func (h *host) tryURLs(fn func(hostURL string) error) (err error) {
+	candidates := h.candidates()
+
+	for n, i := range candidates {
+		err = fn(h.urls[i])
+
+		//? A 4xx only concerns this request, e.g. an unknown key, so another
+		//? URL would reject it as well
+		clientErr := isClientError(err)
+		h.report(i, err == nil || clientErr)
+
+		if err == nil || clientErr {
+			return err
+		}
+
+		if n < len(candidates)-1 {
+			zap.S().Warnw("Host URL failed, failing over",
+				"host", h.name,
+				"url", h.urls[i],
+				"error", err,
+			)
+		}
+	}
+
+	return err
+}
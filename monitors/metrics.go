@@ -0,0 +1,164 @@
+package monitors
+
+import (
+	"net"
+	"net/http"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// Serves the state of all monitors and hosts in the Prometheus text format
+type metricsHandler struct {
+	monitors []Monitor
+	states   []*monitorState
+	hosts    map[string]*host
+}
+
+func (mh *metricsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	metrics := &prometheusMetrics{}
+
+	for i, m := range mh.monitors {
+		last, runErrors, lag := mh.states[i].snapshot()
+		labels := []string{"monitor", m.Name(), "type", m.Type()}
+
+		metrics.add(
+			prometheusName("monitor_scheduler_lag_seconds"),
+			"gauge",
+			"Time the latest run of the monitor started later than scheduled",
+			lag.Seconds(),
+			labels...,
+		)
+		metrics.add(
+			prometheusName("monitor_run_errors_total"),
+			"counter",
+			"Number of runs of the monitor that returned an error",
+			float64(runErrors),
+			labels...,
+		)
+
+		// No successful run yet
+		if last.Time.IsZero() {
+			continue
+		}
+
+		metrics.add(
+			prometheusName("monitor_up"),
+			"gauge",
+			"Whether the monitor was up during its last run",
+			statusValue(last.Status),
+			labels...,
+		)
+		metrics.add(
+			prometheusName("monitor_duration_seconds"),
+			"gauge",
+			"Time the last run of the monitor took",
+			last.Duration.Seconds(),
+			labels...,
+		)
+		metrics.add(
+			prometheusName("monitor_last_run_timestamp_seconds"),
+			"gauge",
+			"Time the last run of the monitor started",
+			float64(last.Time.Unix()),
+			labels...,
+		)
+		metrics.addValues(last.Values, labels...)
+	}
+
+	// Sort hosts to keep the output stable
+	names := make([]string, 0, len(mh.hosts))
+	for name := range mh.hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		h := mh.hosts[name]
+		stats := h.stats.snapshot()
+		labels := []string{"host", h.name, "type", h.hostType}
+
+		metrics.add(
+			prometheusName("host_pushes_total"),
+			"counter",
+			"Number of pushes to the host by outcome",
+			float64(stats.successes),
+			append(labels, "result", "success")...,
+		)
+		metrics.add(
+			prometheusName("host_pushes_total"),
+			"counter",
+			"Number of pushes to the host by outcome",
+			float64(stats.failures),
+			append(labels, "result", "failure")...,
+		)
+		metrics.add(
+			prometheusName("host_last_push_latency_seconds"),
+			"gauge",
+			"Time the latest push to the host took",
+			stats.lastLatency.Seconds(),
+			labels...,
+		)
+		if !stats.lastSuccess.IsZero() {
+			metrics.add(
+				prometheusName("host_last_success_timestamp_seconds"),
+				"gauge",
+				"Time of the latest successful push to the host",
+				float64(stats.lastSuccess.Unix()),
+				labels...,
+			)
+		}
+		if !stats.lastFailure.IsZero() {
+			metrics.add(
+				prometheusName("host_last_failure_timestamp_seconds"),
+				"gauge",
+				"Time of the latest failed push to the host",
+				float64(stats.lastFailure.Unix()),
+				labels...,
+			)
+		}
+		if stats.lastError != "" {
+			metrics.add(
+				prometheusName("host_last_error_info"),
+				"gauge",
+				"Error of the latest failed push to the host",
+				1,
+				append(labels, "error", truncateMessage(stats.lastError, 200))...,
+			)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := metrics.writeTo(w); err != nil {
+		zap.S().Debugw("Error writing metrics",
+			"error", err,
+		)
+	}
+}
+
+// Serve `/metrics` on `listen` in the background
+func setupMetricsEndpoint(listen string, monitors []Monitor, states []*monitorState, hosts map[string]*host) {
+	// Listen right away so a taken address is reported during setup
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		zap.S().Panicw("Could not listen for metrics",
+			"address", listen,
+			"error", err,
+		)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", &metricsHandler{monitors: monitors, states: states, hosts: hosts})
+
+	zap.S().Infow("Serving metrics",
+		"address", listener.Addr().String(),
+	)
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			zap.S().Errorw("Stopped serving metrics",
+				"error", err,
+			)
+		}
+	}()
+}
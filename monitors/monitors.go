@@ -1,12 +1,11 @@
 package monitors
 
 import (
-	"fmt"
-	"net/http"
-	"net/url"
+	"sync"
 	"time"
 
 	"github.com/coronon/uptime-robot/config"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
@@ -15,15 +14,67 @@ type Monitor interface {
 	Name() string
 	// Type of this monitor
 	Type() string
-	// Resolved host of this monitor
-	HostURL() string
-	// Key used to identify this monitor on the uptime host
-	Key() string
 	// Interval in seconds this monitor runs
 	Interval() int
 
 	// Run a single iteration of this monitor (periodically called)
-	Run() (status monitorStatus, message string, pingMs int, err error)
+	//
+	// `values` contains all numeric values measured by the run, indexed by a
+	// name in snake_case including the unit, e.g. `usage_percent`
+	Run() (status monitorStatus, message string, pingMs int, values map[string]float64, err error)
+}
+
+// Result of a single monitor run as pushed to a host
+//
+// All fields can be used in the request templates of a host
+type Result struct {
+	// Name of the node running the monitor
+	NodeName string
+	// Name of the monitor
+	Monitor string
+	// Type of the monitor
+	Type string
+	// Key used to identify the monitor on the host
+	Key string
+	// Tags and link to open when clicking a notification, as configured for
+	// the monitor
+	Tags     []string
+	ClickURL string
+
+	// Status, message, ping and values as returned by the monitor
+	Status  monitorStatus
+	Message string
+	Ping    int
+	Values  map[string]float64
+	// Time the monitor took to run
+	Duration time.Duration
+	// Time the monitor started running
+	Time time.Time
+	// Unique ID of the run that produced this result
+	RunID string
+
+	// Status of the previous run, empty for the first run
+	PreviousStatus monitorStatus
+	// Whether the status changed compared to the previous run
+	//
+	// The first run only counts as a change if the monitor is down.
+	Changed bool
+	// How long the previous status lasted, only set if the status changed
+	PreviousDuration time.Duration
+}
+
+// A host the results of a monitor are pushed to
+type destination struct {
+	// Resolved host the results are pushed to
+	host *host
+	// Key used to identify the monitor on the host
+	key string
+	// Tags and click URL of the monitor, used by notifiers
+	tags     []string
+	clickURL string
+
+	// Tracked separately for every destination of a monitor
+	state *pushState
 }
 
 // Schedule monitors to run in background
@@ -38,9 +89,16 @@ func SetupMonitors(c config.Config) {
 		"count", len(c.Monitors),
 	)
 
+	hosts := setupHosts(c)
+
 	// Actually setup monitors based on config
 	monitors := make([]Monitor, len(c.Monitors))
-	monitorKeys := make([]string, len(c.Monitors))
+	destinations := make([][]*destination, len(c.Monitors))
+	states := make([]*monitorState, len(c.Monitors))
+	// Keys only have to be unique on the same host
+	hostKeys := make(map[string]map[string]string)
+	// Monitors without a key by host, which might be provisioned there
+	unkeyed := make(map[string][]provisionedMonitor)
 
 	for i := range c.Monitors {
 		monitor := &c.Monitors[i]
@@ -50,69 +108,119 @@ func SetupMonitors(c config.Config) {
 			"type", monitor.Type,
 		)
 
-		// Check key not reused
-		for k := 0; k < i; k++ {
-			if monitorKeys[k] == monitor.Key {
-				zap.S().Panicw("Key is not unique",
-					"monitor", monitor.Name,
-					"key", monitor.Key,
+		// Collect all hosts this monitor pushes to
+		monitorHosts := monitor.Hosts
+		if monitor.Host != "" || monitor.Key != "" {
+			monitorHosts = append(
+				[]config.MonitorHost{{Host: monitor.Host, Key: monitor.Key}},
+				monitorHosts...,
+			)
+		}
+		heartbeatHost := setupHeartbeatHost(monitor)
+		if len(monitorHosts) == 0 && heartbeatHost == nil {
+			zap.S().Panicw("No host defined for monitor",
+				"monitor", monitor.Name,
+			)
+		}
+
+		for _, monitorHost := range monitorHosts {
+			host, ok := hosts[monitorHost.Host]
+			if !ok {
+				zap.S().Panicw("Could not find host",
+					"host", monitorHost.Host,
 				)
 			}
-		}
-		monitorKeys[i] = monitor.Key
-		zap.S().Debugw("Key is unique",
-			"monitor", monitor.Name,
-			"key", monitor.Key,
-		)
 
-		// Determine host
-		var hostURL string
-		for h := range c.Hosts {
-			host := &c.Hosts[h]
+			// Some host types identify monitors by other means than a key
+			if monitorHost.Key == "" && host.requiresKey {
+				zap.S().Panicw("Missing key for host",
+					"monitor", monitor.Name,
+					"host", monitorHost.Host,
+				)
+			}
 
-			if host.Name == monitor.Host {
-				zap.S().Debugw("Found matching host",
-					"name", host.Name,
-					"url", host.URL,
+			// Check key not reused on this host
+			keys, ok := hostKeys[monitorHost.Host]
+			if !ok {
+				keys = make(map[string]string)
+				hostKeys[monitorHost.Host] = keys
+			}
+			if other, ok := keys[monitorHost.Key]; ok && monitorHost.Key != "" {
+				zap.S().Panicw("Key is not unique",
+					"monitor", monitor.Name,
+					"other_monitor", other,
+					"host", monitorHost.Host,
+					"key", monitorHost.Key,
 				)
-				hostURL = host.URL
-				break
 			}
-		}
-		if hostURL == "" {
-			zap.S().Panicw("Could not find host",
-				"host", monitor.Host,
+			keys[monitorHost.Key] = monitor.Name
+			zap.S().Debugw("Key is unique",
+				"monitor", monitor.Name,
+				"host", monitorHost.Host,
+				"key", monitorHost.Key,
 			)
+
+			state := setupPushState(monitor)
+			destinations[i] = append(destinations[i], &destination{
+				host:     host,
+				key:      monitorHost.Key,
+				tags:     monitor.Tags,
+				clickURL: monitor.ClickURL,
+				state:    state,
+			})
+
+			if monitorHost.Key == "" {
+				unkeyed[monitorHost.Host] = append(unkeyed[monitorHost.Host], provisionedMonitor{
+					name:   monitor.Name,
+					maxGap: state.maxGap(),
+				})
+			}
 		}
 
-		// Ensure host ends with a trailing '/'
-		if hostURL[len(hostURL)-1:] != "/" {
-			zap.S().Debugw("Adding trailing '/' to host url",
-				"host", monitor.Host,
-				"old_url", hostURL,
-			)
-			hostURL = hostURL + "/"
+		// Pings to the monitor's own URLs are handled like any other push
+		if heartbeatHost != nil {
+			//? Registered like configured hosts so its pushes show up in metrics
+			if _, ok := hosts[heartbeatHost.name]; ok {
+				zap.S().Panicw("Host is not unique",
+					"host", heartbeatHost.name,
+				)
+			}
+			hosts[heartbeatHost.name] = heartbeatHost
+
+			destinations[i] = append(destinations[i], setupHeartbeatDestination(monitor, heartbeatHost))
 		}
 
 		// Setup based on monitor type
 		switch monitor.Type {
 		case "alive":
-			monitors[i] = setupAliveMonitor(hostURL, monitor)
+			monitors[i] = setupAliveMonitor(monitor)
 		case "disk_usage":
-			monitors[i] = setupDiskUsageMonitor(hostURL, monitor)
+			monitors[i] = setupDiskUsageMonitor(monitor)
 		case "email_ping":
-			monitors[i] = setupEmailPingMonitor(hostURL, monitor)
+			monitors[i] = setupEmailPingMonitor(monitor)
 		default:
 			zap.S().Panicw("Unknown monitor type",
 				"type", monitor.Type,
 			)
 		}
+
+		states[i] = &monitorState{}
+	}
+
+	// Create monitors on hosts that support it before pushing to them
+	for name, monitors := range unkeyed {
+		provisionMonitorsOnHost(hosts[name], monitors)
+	}
+
+	// Expose metrics if enabled
+	if c.MetricsListen != "" {
+		setupMetricsEndpoint(c.MetricsListen, monitors, states, hosts)
 	}
 
 	// Run monitors
 	zap.L().Info("Starting monitors...")
 	for i := range monitors {
-		go runMonitorPeriodically(monitors[i])
+		go runMonitorPeriodically(monitors[i], c.NodeName, destinations[i], states[i])
 	}
 	zap.L().Info("All monitors started")
 }
@@ -125,108 +233,379 @@ const (
 	StatusDown monitorStatus = "down"
 )
 
-// Pushes a monitors state to an uptime host handling creation of the correctly
-// formatted URL
+// Latest state of a monitor, shared between its runs
+type monitorState struct {
+	mu sync.Mutex
+
+	// Result of the latest successful run
+	last Result
+	// Time the current status was first seen
+	since time.Time
+	// Number of runs that returned an error
+	runErrors uint64
+	// Time the latest run started later than scheduled
+	lag time.Duration
+}
+
+// Record that a run was started `lag` later than scheduled
+func (s *monitorState) started(lag time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lag = lag
+}
+
+// Record the result of a successful run, filling in how it compares to the
+// previous one
+func (s *monitorState) finished(r *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r.PreviousStatus = s.last.Status
+	if r.PreviousStatus == "" {
+		r.Changed = r.Status != StatusUp
+		s.since = r.Time
+	} else if r.Status != r.PreviousStatus {
+		r.Changed = true
+		r.PreviousDuration = r.Time.Sub(s.since)
+		s.since = r.Time
+	}
+
+	s.last = *r
+}
+
+// Record a run that returned an error
+func (s *monitorState) failed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runErrors++
+}
+
+// Get a consistent copy of the current state
+func (s *monitorState) snapshot() (last Result, runErrors uint64, lag time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.last, s.runErrors, s.lag
+}
+
+// Controls when the result of a monitor is pushed to its host
+type pushMode string
+
+const (
+	// Push the result of every single run
+	PushModeAlways pushMode = "always"
+	// Push when status or message change and otherwise only keep the host's
+	// heartbeat alive
+	PushModeOnChange pushMode = "on_change"
+)
+
+// Remembers what was last pushed for a monitor to decide whether a new result
+// has to be pushed according to its push mode
+type pushState struct {
+	mode      pushMode
+	interval  time.Duration
+	heartbeat time.Duration
+
+	mu          sync.Mutex
+	lastStatus  monitorStatus
+	lastMessage string
+	lastPush    time.Time
+}
+
+// Whether a result with `status` and `message` should be pushed to the host
+func (s *pushState) shouldPush(status monitorStatus, message string) bool {
+	if s.mode != PushModeOnChange {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastPush.IsZero() || status != s.lastStatus || message != s.lastMessage {
+		return true
+	}
+
+	// Push on the last run before the heartbeat would be exceeded, as the next
+	// run only happens after another interval
+	return time.Since(s.lastPush)+s.interval > s.heartbeat
+}
+
+// Whether the next result will be pushed to the host regardless of its status
+// and message
+func (s *pushState) heartbeatDue() bool {
+	if s.mode != PushModeOnChange {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastPush.IsZero() || time.Since(s.lastPush)+s.interval > s.heartbeat
+}
+
+// Longest time expected between two pushes, not including the duration of runs
+func (s *pushState) maxGap() time.Duration {
+	if s.mode == PushModeOnChange && s.heartbeat > s.interval {
+		return s.heartbeat
+	}
+
+	return s.interval
+}
+
+// Record that a result was successfully pushed to the host
 //
-// Returns the HTTP requests response/error
-func pushToHost(
-	host string,
-	key string,
-	status monitorStatus,
-	message string,
-	pingMs int,
-) (resp *http.Response, err error) {
-	// Parse base URL
-	baseUrl, err := url.Parse(host)
+// Failed pushes are not recorded so they are retried on the next run
+func (s *pushState) pushed(status monitorStatus, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastStatus = status
+	s.lastMessage = message
+	s.lastPush = time.Now()
+}
+
+// Setup the push state of a monitor based on its configured push mode
+func setupPushState(monitor *config.Monitor) *pushState {
+	state := &pushState{
+		mode:      pushMode(monitor.PushMode),
+		interval:  time.Duration(monitor.Interval) * time.Second,
+		heartbeat: time.Duration(monitor.Heartbeat) * time.Second,
+	}
+
+	switch state.mode {
+	case "":
+		state.mode = PushModeAlways
+	case PushModeAlways:
+	case PushModeOnChange:
+		if monitor.Heartbeat == 0 {
+			zap.S().Panicw("Missing paramter for monitor",
+				"name", monitor.Name,
+				"type", monitor.Type,
+				"paramter", "heartbeat",
+			)
+		}
+		if monitor.Heartbeat <= monitor.Interval {
+			zap.S().Warnw("Heartbeat is not longer than interval, every result will be pushed",
+				"name", monitor.Name,
+				"interval", monitor.Interval,
+				"heartbeat", monitor.Heartbeat,
+			)
+		}
+	default:
+		zap.S().Panicw("Unknown push mode",
+			"name", monitor.Name,
+			"push_mode", monitor.PushMode,
+		)
+	}
+
+	return state
+}
+
+// Setup the destination pinging the heartbeat and failure URLs of a monitor
+func setupHeartbeatDestination(monitor *config.Monitor, heartbeatHost *host) *destination {
+	//? Dead man's switches expect a ping after every run, regardless of the
+	//? push mode of the monitor
+	return &destination{
+		host:     heartbeatHost,
+		tags:     monitor.Tags,
+		clickURL: monitor.ClickURL,
+		state: &pushState{
+			mode:     PushModeAlways,
+			interval: time.Duration(monitor.Interval) * time.Second,
+		},
+	}
+}
+
+// Push a single result to this destination if its push mode requires it
+func (d *destination) push(m Monitor, r Result) {
+	r.Key = d.key
+	r.Tags = d.tags
+	r.ClickURL = d.clickURL
+
+	if d.host.onlyChanges && !r.Changed {
+		zap.S().Debugw("Skipping push of result without status change",
+			"name", m.Name(),
+			"host", d.host.name,
+			"key", d.key,
+			"status", r.Status,
+		)
+		return
+	}
+
+	if !d.state.shouldPush(r.Status, r.Message) {
+		zap.S().Debugw("Skipping push of unchanged result",
+			"name", m.Name(),
+			"host", d.host.name,
+			"key", d.key,
+			"status", r.Status,
+			"message", r.Message,
+		)
+		return
+	}
+
+	err := pushToHost(d.host, &r)
+	stats := d.host.stats.snapshot()
+
 	if err != nil {
-		zap.S().DPanic("Malformed host URL",
-			"url", host,
-			"error", err.Error(),
+		zap.S().Warnw("Error pushing to host",
+			"name", m.Name(),
+			"type", m.Type(),
+			"host", d.host.name,
+			"key", d.key,
+			"interval", m.Interval(),
+			"error", err,
+			"host_failures", stats.failures,
+			"host_last_success", stats.lastSuccess,
 		)
-		return nil, err
-	}
-
-	// Add key path
-	//? We already ensure that host ends with a trailing / in SetupMonitors
-	baseUrl.Path += key
-
-	// Add dynamic status information
-	params := url.Values{}
-	params.Add("status", string(status))
-	params.Add("msg", message)
-	params.Add("ping", fmt.Sprint(pingMs))
-	baseUrl.RawQuery = params.Encode()
-
-	// Build final URL
-	url := baseUrl.String()
-
-	zap.S().Debugw("Pushing to host",
-		"host", host,
-		"key", key,
-		"status", status,
-		"message", message,
-		"pingMs", pingMs,
-		"url", url,
+		return
+	}
+
+	zap.S().Debugw("Pushed to host",
+		"name", m.Name(),
+		"host", d.host.name,
+		"key", d.key,
+		"latency", stats.lastLatency,
+		"host_successes", stats.successes,
+		"host_failures", stats.failures,
 	)
+	d.state.pushed(r.Status, r.Message)
+}
+
+// Signal the start of a run to this destination in the background if its host
+// supports it
+//
+// Starts are only signaled if the result is going to be pushed anyway, and runs
+// that fail are pushed as down to every destination whose start was signaled,
+// so a host never sees a run that does not finish.
+//
+// Returns a channel that is closed once the start was signaled, nil if no start
+// is signaled
+func (d *destination) start(m Monitor, r Result) <-chan struct{} {
+	r.Key = d.key
+	r.Tags = d.tags
+	r.ClickURL = d.clickURL
+
+	if _, ok := d.host.backend.(startNotifier); !ok || !d.state.heartbeatDue() {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		if err := pushStartToHost(d.host, &r); err != nil {
+			zap.S().Warnw("Error signaling start to host",
+				"name", m.Name(),
+				"type", m.Type(),
+				"host", d.host.name,
+				"key", d.key,
+				"error", err,
+			)
+		}
+	}()
+
+	return done
+}
 
-	return http.Get(url)
+// Push the result of a run once its start was signaled in the background
+func (d *destination) finish(m Monitor, r Result, started <-chan struct{}) {
+	//? The result must not overtake the start of its run
+	if started != nil {
+		<-started
+	}
+
+	d.push(m, r)
+}
+
+// Push a run that failed with `err` as down to the destinations that expect to
+// see every run
+//
+// These are the hosts that were told the run started and the monitor's own
+// heartbeat and failure URLs. Other hosts only receive results of runs that
+// did not fail.
+func pushFailedRun(m Monitor, r Result, err error, destinations []*destination, started []<-chan struct{}) {
+	r.Status = StatusDown
+	r.Message = "Error running monitor: " + err.Error()
+
+	for i, d := range destinations {
+		if _, ok := d.host.backend.(*heartbeatBackend); ok || started[i] != nil {
+			go d.finish(m, r, started[i])
+		}
+	}
 }
 
 // Run a monitor periodically based on its configured interval
 //
 // Should be called in a go-routine
-func runMonitorPeriodically(m Monitor) {
+func runMonitorPeriodically(m Monitor, nodeName string, destinations []*destination, state *monitorState) {
 	sleepTime := time.Duration(m.Interval()) * time.Second
+	wakeUp := time.Now()
 
 	for {
+		state.started(time.Since(wakeUp))
+
 		go func() {
 			zap.S().Debugw("Running monitor",
 				"name", m.Name(),
 				"type", m.Type(),
-				"host", m.HostURL(),
-				"key", m.Key(),
+				"destinations", len(destinations),
 				"interval", m.Interval(),
 			)
 
-			status, message, ping, err := m.Run()
+			result := Result{
+				NodeName: nodeName,
+				Monitor:  m.Name(),
+				Type:     m.Type(),
+				Time:     time.Now(),
+				RunID:    uuid.New().String(),
+			}
+
+			// Let hosts know a run started before actually running
+			//? The run does not wait for the starts to be signaled, so a slow
+			//? or unreachable host does not delay it. Only the result pushed to
+			//? that host waits for its start.
+			started := make([]<-chan struct{}, len(destinations))
+			for i, d := range destinations {
+				started[i] = d.start(m, result)
+			}
+
+			runStart := time.Now()
+			status, message, ping, values, err := m.Run()
 			if err != nil {
 				zap.S().Warnw("Error running monitor",
 					"name", m.Name(),
 					"type", m.Type(),
-					"host", m.HostURL(),
-					"key", m.Key(),
 					"interval", m.Interval(),
 					"error", err,
 				)
-			} else {
-				// Only push to host if monitor did not error (down should not be an error)
-				resp, err := pushToHost(m.HostURL(), m.Key(), status, message, ping)
-
-				if err != nil {
-					zap.S().Warnw("Error pushing to host",
-						"name", m.Name(),
-						"type", m.Type(),
-						"host", m.HostURL(),
-						"key", m.Key(),
-						"interval", m.Interval(),
-						"error", err,
-					)
-				} else if resp.StatusCode != 200 {
-					zap.S().Warnw("Error pushing to host",
-						"name", m.Name(),
-						"type", m.Type(),
-						"host", m.HostURL(),
-						"key", m.Key(),
-						"interval", m.Interval(),
-						"resp_statuscode", resp.StatusCode,
-					)
-				}
+				state.failed()
+
+				result.Duration = time.Since(runStart)
+				pushFailedRun(m, result, err, destinations, started)
+				return
+			}
+
+			result.Status = status
+			result.Message = message
+			result.Ping = ping
+			result.Values = values
+			result.Duration = time.Since(runStart)
+			state.finished(&result)
+
+			// Only push to hosts if monitor did not error (down should not be an error)
+			//? Every destination is pushed to independently so a slow or
+			//? unreachable host does not delay the others
+			for i, d := range destinations {
+				go d.finish(m, result, started[i])
 			}
 		}()
 
 		// Always wait for interval
 		//? The interval does not depend on the time the monitor and pushing it's
 		//? result take
+		wakeUp = time.Now().Add(sleepTime)
 		time.Sleep(sleepTime)
 	}
 }
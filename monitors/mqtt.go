@@ -0,0 +1,347 @@
+package monitors
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Minimal MQTT 3.1.1 client supporting everything needed to publish results
+//
+// Messages are published with QoS 1, so a publish only succeeds once the broker
+// acknowledged it. Subscriptions are not supported.
+type mqttClient struct {
+	conn net.Conn
+	// Interval in which the broker is pinged
+	keepAlive time.Duration
+
+	// Serializes writes to conn
+	writeMu sync.Mutex
+
+	mu sync.Mutex
+	// Last used packet identifier
+	lastID uint16
+	// Waiting publishes by packet identifier
+	acks map[uint16]chan struct{}
+
+	// Closed once the connection is lost
+	done chan struct{}
+	// Reason the connection was lost
+	err error
+}
+
+// Options used to connect to a broker
+type mqttConnectOptions struct {
+	clientID string
+	username string
+	password string
+	// Interval in which the connection is kept alive
+	keepAlive time.Duration
+
+	// Published by the broker if the connection is lost unexpectedly
+	willTopic   string
+	willPayload []byte
+	willRetain  bool
+}
+
+// MQTT control packet types
+const (
+	mqttConnect  byte = 1
+	mqttConnAck  byte = 2
+	mqttPublish  byte = 3
+	mqttPubAck   byte = 4
+	mqttPingReq  byte = 12
+	mqttPingResp byte = 13
+)
+
+// Connect to the broker at `address` and wait until it accepted the connection
+func dialMQTT(address string, useTLS bool, opts mqttConnectOptions, timeout time.Duration) (*mqttClient, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+	var err error
+	if useTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, nil)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &mqttClient{
+		conn:      conn,
+		keepAlive: opts.keepAlive,
+		acks:      make(map[uint16]chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := c.write(mqttConnect<<4, encodeMQTTConnect(opts)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	packetType, body, err := readMQTTPacket(reader)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if packetType>>4 != mqttConnAck || len(body) != 2 {
+		conn.Close()
+		return nil, errors.New("expected CONNACK from broker")
+	}
+	if body[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("broker refused connection with return code %v", body[1])
+	}
+	conn.SetDeadline(time.Time{})
+
+	go c.readLoop(reader)
+	go c.pingLoop()
+
+	return c, nil
+}
+
+// Publish `payload` to `topic` and wait for the broker to acknowledge it
+func (c *mqttClient) publish(topic string, payload []byte, retain bool, timeout time.Duration) error {
+	c.mu.Lock()
+	c.lastID++
+	if c.lastID == 0 {
+		c.lastID = 1
+	}
+	id := c.lastID
+	ack := make(chan struct{})
+	c.acks[id] = ack
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.acks, id)
+		c.mu.Unlock()
+	}()
+
+	// QoS 1
+	flags := byte(1 << 1)
+	if retain {
+		flags |= 1
+	}
+
+	body := appendMQTTString(nil, topic)
+	body = binary.BigEndian.AppendUint16(body, id)
+	body = append(body, payload...)
+
+	if err := c.write(mqttPublish<<4|flags, body); err != nil {
+		c.closeWithError(err)
+		return err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ack:
+		return nil
+	case <-c.done:
+		return c.err
+	case <-timer.C:
+		return errors.New("timed out waiting for PUBACK")
+	}
+}
+
+// Whether the connection to the broker is still alive
+func (c *mqttClient) alive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Close the connection, failing all waiting publishes with `err`
+func (c *mqttClient) closeWithError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.alive() {
+		return
+	}
+
+	c.err = err
+	close(c.done)
+	c.conn.Close()
+}
+
+// Write a single packet
+func (c *mqttClient) write(header byte, body []byte) error {
+	packet := []byte{header}
+	packet = appendMQTTLength(packet, len(body))
+	packet = append(packet, body...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_, err := c.conn.Write(packet)
+	return err
+}
+
+// Handle packets sent by the broker until the connection is lost
+//
+// As the broker is pinged every half keep alive interval, the connection is
+// considered lost if nothing was received for 1.5 times the interval, which
+// detects half-open connections.
+//
+// Should be called in a go-routine
+func (c *mqttClient) readLoop(reader *bufio.Reader) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(c.keepAlive * 3 / 2))
+
+		packetType, body, err := readMQTTPacket(reader)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			err = errors.New("broker did not respond to pings")
+		}
+		if err != nil {
+			c.closeWithError(err)
+			return
+		}
+
+		switch packetType >> 4 {
+		case mqttPubAck:
+			if len(body) < 2 {
+				continue
+			}
+			id := binary.BigEndian.Uint16(body)
+
+			c.mu.Lock()
+			if ack, ok := c.acks[id]; ok {
+				close(ack)
+				delete(c.acks, id)
+			}
+			c.mu.Unlock()
+		case mqttPingResp:
+		default:
+			// Nothing else is expected without subscriptions
+		}
+	}
+}
+
+// Ping the broker periodically so it does not consider the connection dead
+//
+// Should be called in a go-routine
+func (c *mqttClient) pingLoop() {
+	ticker := time.NewTicker(c.keepAlive / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.write(mqttPingReq<<4, nil); err != nil {
+				c.closeWithError(err)
+				return
+			}
+		}
+	}
+}
+
+// Build the variable header and payload of a CONNECT packet
+func encodeMQTTConnect(opts mqttConnectOptions) []byte {
+	// Always start a clean session as nothing is subscribed
+	flags := byte(1 << 1)
+	if opts.willTopic != "" {
+		// Will with QoS 1
+		flags |= 1<<2 | 1<<3
+		if opts.willRetain {
+			flags |= 1 << 5
+		}
+	}
+	if opts.username != "" {
+		flags |= 1 << 7
+		if opts.password != "" {
+			flags |= 1 << 6
+		}
+	}
+
+	body := appendMQTTString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(opts.keepAlive.Seconds()))
+
+	body = appendMQTTString(body, opts.clientID)
+	if opts.willTopic != "" {
+		body = appendMQTTString(body, opts.willTopic)
+		body = appendMQTTString(body, string(opts.willPayload))
+	}
+	if opts.username != "" {
+		body = appendMQTTString(body, opts.username)
+		if opts.password != "" {
+			body = appendMQTTString(body, opts.password)
+		}
+	}
+
+	return body
+}
+
+// Read a single packet returning its first header byte and its body
+func readMQTTPacket(reader *bufio.Reader) (byte, []byte, error) {
+	header, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Remaining length is encoded in up to 4 bytes, 7 bits each
+	length := 0
+	for shift := 0; ; shift += 7 {
+		if shift > 21 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return 0, nil, err
+	}
+
+	return header, body, nil
+}
+
+// Append the remaining length of a packet
+func appendMQTTLength(b []byte, length int) []byte {
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+
+		if length == 0 {
+			return b
+		}
+	}
+}
+
+// Append a length prefixed UTF-8 string
+func appendMQTTString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,83 @@
+package monitors
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Maximum number of characters of a monitor message included in notifications
+const maxNotificationMessage = 1000
+
+// Colors used by notifiers to highlight the status of a monitor
+const (
+	colorUp   = 0x2eb886
+	colorDown = 0xa30200
+)
+
+// Human readable summary of a status change, shared by all notifier backends
+type notification struct {
+	// e.g. "Available disk space on my.hostname is down"
+	title string
+	// Message of the monitor, shortened if too long
+	message string
+	// e.g. "Was up for 2h13m5s", empty if there was no previous status
+	previous string
+	// Color highlighting the status
+	color int
+}
+
+// Summarize a result for humans
+func newNotification(r *Result) notification {
+	n := notification{
+		title:   fmt.Sprintf("%s on %s is %s", r.Monitor, r.NodeName, r.Status),
+		message: truncateMessage(r.Message, maxNotificationMessage),
+		color:   colorDown,
+	}
+	if r.Status == StatusUp {
+		n.color = colorUp
+	}
+	if r.PreviousStatus != "" {
+		n.previous = fmt.Sprintf("Was %s for %s", r.PreviousStatus, r.PreviousDuration.Round(time.Second))
+	}
+
+	return n
+}
+
+// Plain text of the notification, one part per line
+func (n notification) text() string {
+	if details := n.details(); details != "" {
+		return n.title + "\n" + details
+	}
+
+	return n.title
+}
+
+// Plain text of the notification without its title
+func (n notification) details() string {
+	lines := make([]string, 0, 2)
+	if n.message != "" {
+		lines = append(lines, n.message)
+	}
+	if n.previous != "" {
+		lines = append(lines, n.previous)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Color of the notification as hex string without leading '#'
+func (n notification) hexColor() string {
+	return fmt.Sprintf("%06x", n.color)
+}
+
+// Shorten `message` to at most `max` characters
+func truncateMessage(message string, max int) string {
+	if utf8.RuneCountInString(message) <= max {
+		return message
+	}
+
+	runes := []rune(message)
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,106 @@
+package monitors
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"time"
+)
+
+// Sizes of NSCA packets and their fields
+const (
+	nscaInitPacketSize = 132
+	nscaIVSize         = 128
+	nscaHostSize       = 64
+	nscaServiceSize    = 128
+	nscaOutputSize     = 512
+	nscaVersion        = 3
+)
+
+// Encryption methods supported by NSCA
+const (
+	nscaEncryptNone = 0
+	nscaEncryptXOR  = 1
+)
+
+// Passive check result as submitted via NSCA
+type nscaCheckResult struct {
+	host    string
+	service string
+	// 0 = OK, 1 = WARNING, 2 = CRITICAL, 3 = UNKNOWN
+	exitStatus int
+	output     string
+}
+
+// Submit a single passive check result to the NSCA daemon at `address`
+//
+// The daemon starts by sending an IV and timestamp, which are used to encrypt
+// the data packet and to prevent replays.
+func sendNSCA(address string, encryption int, password string, result nscaCheckResult, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	init := make([]byte, nscaInitPacketSize)
+	if _, err := io.ReadFull(conn, init); err != nil {
+		return fmt.Errorf("failed to read NSCA init packet: %w", err)
+	}
+	iv := init[:nscaIVSize]
+	timestamp := binary.BigEndian.Uint32(init[nscaIVSize:])
+
+	packet := encodeNSCAPacket(result, timestamp)
+
+	switch encryption {
+	case nscaEncryptNone:
+	case nscaEncryptXOR:
+		for i := range packet {
+			packet[i] ^= iv[i%len(iv)]
+			if password != "" {
+				packet[i] ^= password[i%len(password)]
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported NSCA encryption method %v", encryption)
+	}
+
+	_, err = conn.Write(packet)
+	return err
+}
+
+// Build an unencrypted data packet
+func encodeNSCAPacket(result nscaCheckResult, timestamp uint32) []byte {
+	packet := make([]byte, 0, 16+nscaHostSize+nscaServiceSize+nscaOutputSize+2)
+
+	packet = binary.BigEndian.AppendUint16(packet, nscaVersion)
+	// Padding
+	packet = append(packet, 0, 0)
+	// CRC32 is calculated with this field set to zero
+	packet = binary.BigEndian.AppendUint32(packet, 0)
+	packet = binary.BigEndian.AppendUint32(packet, timestamp)
+	packet = binary.BigEndian.AppendUint16(packet, uint16(result.exitStatus))
+	packet = appendNSCAString(packet, result.host, nscaHostSize)
+	packet = appendNSCAString(packet, result.service, nscaServiceSize)
+	packet = appendNSCAString(packet, result.output, nscaOutputSize)
+	// Padding
+	packet = append(packet, 0, 0)
+
+	binary.BigEndian.PutUint32(packet[4:], crc32.ChecksumIEEE(packet))
+
+	return packet
+}
+
+// Append a null terminated string padded to `size` bytes, truncating it if
+// necessary
+func appendNSCAString(b []byte, s string, size int) []byte {
+	if len(s) > size-1 {
+		s = s[:size-1]
+	}
+
+	b = append(b, s...)
+	return append(b, make([]byte, size-len(s))...)
+}
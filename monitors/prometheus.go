@@ -0,0 +1,127 @@
+package monitors
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Prefix of all metric names exposed in the Prometheus format
+const prometheusNamespace = "uptime_robot"
+
+// A set of metrics in the Prometheus text exposition format
+//
+// Samples of the same metric are grouped below a single HELP/TYPE header no
+// matter in which order they were added.
+type prometheusMetrics struct {
+	families []*prometheusFamily
+	index    map[string]*prometheusFamily
+}
+
+// All samples of a single metric
+type prometheusFamily struct {
+	name       string
+	metricType string
+	help       string
+	samples    []string
+}
+
+// Add a sample of the metric `name`
+//
+// `labels` are given as alternating label names and values.
+func (p *prometheusMetrics) add(name string, metricType string, help string, value float64, labels ...string) {
+	if p.index == nil {
+		p.index = make(map[string]*prometheusFamily)
+	}
+
+	family, ok := p.index[name]
+	if !ok {
+		family = &prometheusFamily{name: name, metricType: metricType, help: help}
+		p.index[name] = family
+		p.families = append(p.families, family)
+	}
+
+	var sample strings.Builder
+	sample.WriteString(name)
+	if len(labels) > 0 {
+		sample.WriteString("{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				sample.WriteString(",")
+			}
+			fmt.Fprintf(&sample, "%s=\"%s\"", labels[i], escapePrometheusLabel(labels[i+1]))
+		}
+		sample.WriteString("}")
+	}
+	sample.WriteString(" ")
+	sample.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+
+	family.samples = append(family.samples, sample.String())
+}
+
+// Add a gauge for every value of a monitor result
+func (p *prometheusMetrics) addValues(values map[string]float64, labels ...string) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p.add(
+			prometheusName("monitor_"+name),
+			"gauge",
+			"Value '"+name+"' measured by the last run of the monitor",
+			values[name],
+			labels...,
+		)
+	}
+}
+
+// Write all metrics to `w`
+func (p *prometheusMetrics) writeTo(w io.Writer) error {
+	for _, family := range p.families {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s\n",
+			family.name, family.help,
+			family.name, family.metricType,
+			strings.Join(family.samples, "\n"),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Build a valid metric name in the uptime_robot namespace
+func prometheusName(name string) string {
+	var b strings.Builder
+	b.WriteString(prometheusNamespace + "_")
+
+	for _, c := range name {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' {
+			b.WriteRune(c)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+
+	return b.String()
+}
+
+// Escape a label value according to the text exposition format
+func escapePrometheusLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// Convert a status to the value of an up/down gauge
+func statusValue(status monitorStatus) float64 {
+	if status == StatusUp {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,155 @@
+package monitors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/coronon/uptime-robot/config"
+	"go.uber.org/zap"
+)
+
+// Uptime-Kuma's push format, used if a host does not define its own
+const (
+	defaultRequestMethod = http.MethodGet
+	defaultRequestPath   = "{{.Key}}"
+)
+
+var defaultRequestQuery = map[string]string{
+	"status": "{{.Status}}",
+	"msg":    "{{.Message}}",
+	"ping":   "{{.Ping}}",
+}
+
+// Functions available in all templates in addition to the builtin ones
+var templateFuncs = template.FuncMap{
+	// Encode a value as JSON, e.g. to safely embed a message in a JSON body
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Templates used to build the HTTP request pushing a result to a host
+type requestTemplate struct {
+	method  string
+	path    *template.Template
+	query   map[string]*template.Template
+	headers map[string]*template.Template
+	// nil if requests should not have a body
+	body *template.Template
+}
+
+// Build the HTTP request pushing `r` to `hostURL`
+func (t *requestTemplate) build(hostURL string, r *Result) (*http.Request, error) {
+	// Parse base URL
+	baseUrl, err := url.Parse(hostURL)
+	if err != nil {
+		return nil, fmt.Errorf("malformed host URL: %w", err)
+	}
+
+	// Add path
+	//? We already ensure that host ends with a trailing / in setupHost
+	path, err := renderTemplate(t.path, r)
+	if err != nil {
+		return nil, err
+	}
+	baseUrl.Path += path
+
+	// Add dynamic status information
+	params := baseUrl.Query()
+	for name, tmpl := range t.query {
+		value, err := renderTemplate(tmpl, r)
+		if err != nil {
+			return nil, err
+		}
+		params.Add(name, value)
+	}
+	baseUrl.RawQuery = params.Encode()
+
+	var body io.Reader
+	if t.body != nil {
+		rendered, err := renderTemplate(t.body, r)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(rendered)
+	}
+
+	req, err := http.NewRequest(t.method, baseUrl.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, tmpl := range t.headers {
+		value, err := renderTemplate(tmpl, r)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(name, value)
+	}
+
+	return req, nil
+}
+
+// Execute `tmpl` with `r` and return the result as a string
+func renderTemplate(tmpl *template.Template, r *Result) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, r); err != nil {
+		return "", fmt.Errorf("failed to render template %v: %w", tmpl.Name(), err)
+	}
+
+	return buf.String(), nil
+}
+
+// Parse a template defined in the config of a host
+func parseHostTemplate(hostConfig *config.Host, name string, text string) *template.Template {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		zap.S().Panicw("Invalid template for host",
+			"name", hostConfig.Name,
+			"template", name,
+			"error", err,
+		)
+	}
+
+	return tmpl
+}
+
+// Setup the request template of a host, defaulting to Uptime-Kuma's push format
+func setupRequestTemplate(hostConfig *config.Host) *requestTemplate {
+	t := &requestTemplate{
+		method:  strings.ToUpper(hostConfig.Method),
+		query:   make(map[string]*template.Template),
+		headers: make(map[string]*template.Template),
+	}
+	if t.method == "" {
+		t.method = defaultRequestMethod
+	}
+
+	// Only fall back to the default format if no part of it was customized
+	path := hostConfig.Path
+	query := hostConfig.Query
+	if hostConfig.Path == "" && len(hostConfig.Query) == 0 && hostConfig.Body == "" {
+		path = defaultRequestPath
+		query = defaultRequestQuery
+	}
+
+	t.path = parseHostTemplate(hostConfig, "path", path)
+	for name, text := range query {
+		t.query[name] = parseHostTemplate(hostConfig, "query."+name, text)
+	}
+	for name, text := range hostConfig.Headers {
+		t.headers[name] = parseHostTemplate(hostConfig, "headers."+name, text)
+	}
+	if hostConfig.Body != "" {
+		t.body = parseHostTemplate(hostConfig, "body", hostConfig.Body)
+	}
+
+	return t
+}
@@ -0,0 +1,244 @@
+package monitors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Minimal Socket.IO v4 client supporting everything needed to call Uptime-Kuma's
+// API
+//
+// Only the HTTP long-polling transport of Engine.IO v4 is used, so no upgrade
+// to WebSockets is necessary. Events are emitted with an acknowledgement that
+// is returned to the caller, events emitted by the server are kept by name so
+// the latest one can be awaited. Binary packets are not supported.
+type socketIOClient struct {
+	// URL of the Engine.IO endpoint, e.g. https://kuma.example.com/socket.io/
+	endpoint string
+	// Session ID assigned by the server
+	sid string
+	ctx context.Context
+
+	// Last used acknowledgement ID
+	lastID int
+	// Packets received but not processed yet
+	pending []string
+	// Arguments of the latest event received by name
+	events map[string][]json.RawMessage
+}
+
+// Handshake sent by the server when opening an Engine.IO session
+type engineIOHandshake struct {
+	SID string `json:"sid"`
+}
+
+// Separates multiple Engine.IO packets in a single polling payload
+const engineIOSeparator = "\x1e"
+
+// Open a session at `endpoint` and connect to the default namespace
+//
+// All requests of the client are bound to `ctx`.
+func dialSocketIO(ctx context.Context, endpoint string) (*socketIOClient, error) {
+	c := &socketIOClient{
+		endpoint: endpoint,
+		ctx:      ctx,
+		events:   make(map[string][]json.RawMessage),
+	}
+
+	packets, err := c.poll()
+	if err != nil {
+		return nil, err
+	}
+	if len(packets) == 0 || packets[0] == "" || packets[0][0] != '0' {
+		return nil, errors.New("socket.io: unexpected handshake")
+	}
+	var handshake engineIOHandshake
+	if err := json.Unmarshal([]byte(packets[0][1:]), &handshake); err != nil {
+		return nil, fmt.Errorf("socket.io: invalid handshake: %w", err)
+	}
+	c.sid = handshake.SID
+
+	if err := c.send("40"); err != nil {
+		return nil, err
+	}
+
+	connected := false
+	err = c.receive(func(packet string) (bool, error) {
+		switch {
+		case strings.HasPrefix(packet, "40"):
+			connected = true
+		case strings.HasPrefix(packet, "44"):
+			return false, fmt.Errorf("socket.io: connection refused: %v", packet[2:])
+		}
+		return connected, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Emit an event and wait for its acknowledgement
+//
+// The first argument of the acknowledgement is decoded into `ack`.
+func (c *socketIOClient) emit(ack any, event string, args ...any) error {
+	c.lastID++
+	id := strconv.Itoa(c.lastID)
+
+	data, err := json.Marshal(append([]any{event}, args...))
+	if err != nil {
+		return err
+	}
+	if err := c.send("42" + id + string(data)); err != nil {
+		return err
+	}
+
+	var result []json.RawMessage
+	err = c.receive(func(packet string) (bool, error) {
+		if !strings.HasPrefix(packet, "43"+id+"[") {
+			return false, nil
+		}
+		return true, json.Unmarshal([]byte(packet[len("43"+id):]), &result)
+	})
+	if err != nil {
+		return err
+	}
+	if len(result) == 0 {
+		return fmt.Errorf("socket.io: empty acknowledgement of %v", event)
+	}
+
+	return json.Unmarshal(result[0], ack)
+}
+
+// Wait until the server emitted `event` at least once
+//
+// The first argument of the latest such event is decoded into `v`.
+func (c *socketIOClient) await(v any, event string) error {
+	err := c.receive(func(packet string) (bool, error) {
+		_, ok := c.events[event]
+		return ok, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	args := c.events[event]
+	if len(args) == 0 {
+		return fmt.Errorf("socket.io: event %v without arguments", event)
+	}
+
+	return json.Unmarshal(args[0], v)
+}
+
+// Close the session
+func (c *socketIOClient) close() error {
+	return c.send("1")
+}
+
+// Process packets until `fn` reports that it is done
+//
+// Pings are answered and events are stored before `fn` is called with every
+// packet received.
+func (c *socketIOClient) receive(fn func(packet string) (bool, error)) error {
+	for {
+		if len(c.pending) == 0 {
+			packets, err := c.poll()
+			if err != nil {
+				return err
+			}
+			c.pending = packets
+		}
+
+		//? Packets after the one `fn` waited for are kept for the next call
+		packet := c.pending[0]
+		c.pending = c.pending[1:]
+
+		switch {
+		case packet == "2":
+			if err := c.send("3"); err != nil {
+				return err
+			}
+			continue
+		case packet == "1":
+			return errors.New("socket.io: session closed by server")
+		case strings.HasPrefix(packet, "42["):
+			var args []json.RawMessage
+			var event string
+			if json.Unmarshal([]byte(packet[2:]), &args) == nil && len(args) > 0 && json.Unmarshal(args[0], &event) == nil {
+				c.events[event] = args[1:]
+			}
+		}
+
+		done, err := fn(packet)
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+// Receive all packets the server has queued, waiting for at least one
+func (c *socketIOClient) poll() ([]string, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.url(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(body, engineIOSeparator), nil
+}
+
+// Send a single packet to the server
+func (c *socketIOClient) send(packet string) error {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.url(), strings.NewReader(packet))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+
+	_, err = c.do(req)
+	return err
+}
+
+func (c *socketIOClient) do(req *http.Request) (string, error) {
+	//? Long polls are only bound by the context, as the server may hold them
+	//? until it pings
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("socket.io: unexpected status %v: %v", resp.Status, truncateMessage(string(body), 200))
+	}
+
+	return string(body), nil
+}
+
+// URL of the polling transport including the session ID
+func (c *socketIOClient) url() string {
+	query := url.Values{}
+	query.Set("EIO", "4")
+	query.Set("transport", "polling")
+	if c.sid != "" {
+		query.Set("sid", c.sid)
+	}
+
+	return c.endpoint + "?" + query.Encode()
+}